@@ -0,0 +1,75 @@
+package httpserver
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+)
+
+// AuditRecord request and response captured by AuditLog middleware.
+// Route is the pattern of the matched route, e.g. /users/:id, Path the concrete request path, e.g. /users/7.
+type AuditRecord struct {
+	Method       string
+	Route        string
+	Path         string
+	RequestID    string
+	StatusCode   int
+	RequestBody  []byte
+	ResponseBody []byte
+}
+
+// AuditLog middleware to capture full request and response bodies and pass them into sink after handler returns.
+// Request body is re-buffered so handler can still read it.
+// Both bodies are kept in memory, use it only on routes with reasonably sized bodies.
+func AuditLog(sink func(AuditRecord)) Middleware {
+	return func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			var reqBody []byte
+			if r.Body != nil {
+				reqBody, _ = ioutil.ReadAll(r.Body)
+				r.Body.Close()
+				r.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+			}
+			aw := &auditWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			next(aw, r)
+			sink(AuditRecord{
+				Method:       r.Method,
+				Route:        RoutePattern(r),
+				Path:         r.URL.Path,
+				RequestID:    r.Header.Get("Request-Id"),
+				StatusCode:   aw.statusCode,
+				RequestBody:  reqBody,
+				ResponseBody: aw.body.Bytes(),
+			})
+		}
+	}
+}
+
+// auditWriter tee the response body while writing it into the wrapped http.ResponseWriter.
+type auditWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (aw *auditWriter) WriteHeader(statusCode int) {
+	aw.statusCode = statusCode
+	aw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (aw *auditWriter) Write(p []byte) (int, error) {
+	aw.body.Write(p)
+	return aw.ResponseWriter.Write(p)
+}
+
+// Flush flush the wrapped http.ResponseWriter if it is http.Flusher.
+func (aw *auditWriter) Flush() {
+	if f, ok := aw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap return the wrapped http.ResponseWriter.
+func (aw *auditWriter) Unwrap() http.ResponseWriter {
+	return aw.ResponseWriter
+}
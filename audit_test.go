@@ -0,0 +1,42 @@
+package httpserver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	var record AuditRecord
+	var handlerBody string
+	srv := New(&Opts{})
+	srv.POST("/audit", func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		handlerBody = string(b)
+		ResponseString(w, http.StatusCreated, "response body")
+	}, AuditLog(func(r AuditRecord) {
+		record = r
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/audit", strings.NewReader("request body"))
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, r)
+
+	if handlerBody != "request body" {
+		t.Errorf("%s expected handler read %s, returned %s", t.Name(), "request body", handlerBody)
+	}
+	if string(record.RequestBody) != "request body" || string(record.ResponseBody) != "response body" {
+		t.Errorf("%s expected both bodies intact, returned %s and %s", t.Name(), record.RequestBody, record.ResponseBody)
+	}
+	if record.StatusCode != http.StatusCreated || record.Method != http.MethodPost || record.Path != "/audit" {
+		t.Errorf("%s expected %d POST /audit, returned %d %s %s", t.Name(), http.StatusCreated, record.StatusCode, record.Method, record.Path)
+	}
+	if record.RequestID == "" || record.RequestID != w.Header().Get("Request-Id") {
+		t.Errorf("%s expected request id %s, returned %s", t.Name(), w.Header().Get("Request-Id"), record.RequestID)
+	}
+	if w.Body.String() != "response body" {
+		t.Errorf("%s expected client receive %s, returned %s", t.Name(), "response body", w.Body.String())
+	}
+}
@@ -0,0 +1,264 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_validator "github.com/go-playground/validator/v10"
+)
+
+// ValidationErrors field level violations returned by BindAndValidate, keyed by json field name.
+// Respond them with ResponseValidationError.
+type ValidationErrors map[string]string
+
+func (ve ValidationErrors) Error() string {
+	fields := make([]string, 0, len(ve))
+	for field := range ve {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	msgs := make([]string, len(fields))
+	for i, field := range fields {
+		msgs[i] = field + ": " + ve[field]
+	}
+	return "httpserver: validation failed: " + strings.Join(msgs, ", ")
+}
+
+// BindAndValidate decode json body of r into dst, pointer to struct, then validate its fields by `validate` tag
+// using github.com/go-playground/validator, e.g. `validate:"required,email"` or `validate:"oneof=admin user"`.
+// Fields of nested struct, or non nil pointer to struct, are validated too, keyed by dotted path e.g. address.city.
+// Unknown rule or malformed parameter is returned as error rather than ValidationErrors.
+// Reading body stops once request context is done, e.g. by RequestTimeout or client gone, protecting handler from slow body.
+// Returns ValidationErrors if any field violates its rules, request context error, i.e. context.Canceled or
+// context.DeadlineExceeded, if body reading is cancelled, otherwise decoding error if any.
+func BindAndValidate(r *http.Request, dst interface{}) error {
+	stop := interruptOnDone(r)
+	err := json.NewDecoder(&contextReader{ctx: r.Context(), r: r.Body}).Decode(dst)
+	stop()
+	if err != nil {
+		return err
+	}
+	return validate(dst)
+}
+
+// contextReader read from r, returning ctx error instead of read error once ctx is done,
+// i.e. read interrupted by interruptOnDone.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := cr.r.Read(p)
+	if ctxErr := cr.ctx.Err(); err != nil && ctxErr != nil {
+		return n, ctxErr
+	}
+	return n, err
+}
+
+// interruptOnDone watch request context of r until stop is called, interrupting read of r.Body blocked on stalled client
+// once the context is done. Over HTTP/1 connection of the server the read deadline is expired, as closing the body
+// waits for the blocked read, otherwise the body is closed.
+func interruptOnDone(r *http.Request) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-r.Context().Done():
+			if c, ok := r.Context().Value(connKey).(net.Conn); ok && r.ProtoMajor == 1 {
+				c.SetReadDeadline(time.Unix(1, 0))
+				return
+			}
+			r.Body.Close()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+// validator validate tags of structs, caching parsed tags per type. Field is named by its json name.
+var validator = newValidator()
+
+func newValidator() *_validator.Validate {
+	v := _validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}
+
+func validate(dst interface{}) (err error) {
+	v := reflect.Indirect(reflect.ValueOf(dst))
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	defer func() {
+		// validator panics on unknown rule or malformed parameter.
+		if rcv := recover(); rcv != nil {
+			err = fmt.Errorf("httpserver: invalid validate rule of %s: %v", v.Type(), rcv)
+		}
+	}()
+	fieldErrs, ok := validator.Struct(v.Interface()).(_validator.ValidationErrors)
+	if !ok || len(fieldErrs) == 0 {
+		return nil
+	}
+	errs := make(ValidationErrors, len(fieldErrs))
+	for _, fe := range fieldErrs {
+		name := fieldPath(v.Type(), fe)
+		if _, ok := errs[name]; !ok {
+			errs[name] = validationMessage(fe)
+		}
+	}
+	return errs
+}
+
+// fieldPath return dotted json path of field of fe within struct type t, e.g. address.city.
+// Embedded struct without json tag is left out of the path as its fields are promoted by json.
+func fieldPath(t reflect.Type, fe _validator.FieldError) string {
+	names := strings.Split(fe.Namespace(), ".")
+	fields := strings.Split(fe.StructNamespace(), ".")
+	if t.Name() != "" { // namespace is prefixed by name of the validated struct type.
+		names, fields = names[1:], fields[1:]
+	}
+	path := make([]string, 0, len(names))
+	for i, name := range names {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct || i >= len(fields) {
+			path = append(path, name)
+			continue
+		}
+		field, ok := t.FieldByName(strings.SplitN(fields[i], "[", 2)[0])
+		if !ok {
+			path = append(path, name)
+			continue
+		}
+		t = field.Type
+		if k := t.Kind(); strings.Contains(fields[i], "[") && (k == reflect.Slice || k == reflect.Array || k == reflect.Map) {
+			t = t.Elem() // element of dived collection, e.g. items[0].
+		}
+		if field.Anonymous && field.Tag.Get("json") == "" {
+			continue
+		}
+		path = append(path, name)
+	}
+	return strings.Join(path, ".")
+}
+
+// validationMessage return violation message of fe.
+func validationMessage(fe _validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min", "gte":
+		return "must be at least " + fe.Param()
+	case "max", "lte":
+		return "must be at most " + fe.Param()
+	case "oneof":
+		return "must be one of " + fe.Param()
+	}
+	if fe.Param() != "" {
+		return fmt.Sprintf("must satisfy %s=%s", fe.Tag(), fe.Param())
+	}
+	return "must satisfy " + fe.Tag()
+}
+
+// BindForm parse form of r, urlencoded body and query, then set fields of dst, pointer to struct, by `form` tag
+// e.g. `form:"name"`. Field without the tag is skipped, as well as field whose value is absent.
+// Supported field types are string, bool, int, uint and float of any size, and slice of them taking all values of
+// repeated key, e.g. tag=a&tag=b. Other field types take the first value. Value of body takes precedence over query.
+// Returns parsing error of the form or of a value not convertible into its field type.
+func BindForm(r *http.Request, dst interface{}) error {
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+	return bindValues(r.Form, dst, "form")
+}
+
+// bindValues set fields of dst, pointer to struct, from values keyed by tag of the fields.
+func bindValues(values url.Values, dst interface{}, tag string) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("httpserver: bind destination must be pointer to struct, got %T", dst)
+	}
+	v = v.Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get(tag), ",")[0]
+		if name == "" || name == "-" || field.PkgPath != "" {
+			continue
+		}
+		vals, ok := values[name]
+		if !ok || len(vals) == 0 {
+			continue
+		}
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Slice {
+			slice := reflect.MakeSlice(fv.Type(), len(vals), len(vals))
+			for j, val := range vals {
+				if err := setValue(slice.Index(j), val); err != nil {
+					return fmt.Errorf("httpserver: invalid value %q of field %s: %v", val, name, err)
+				}
+			}
+			fv.Set(slice)
+			continue
+		}
+		if err := setValue(fv, vals[0]); err != nil {
+			return fmt.Errorf("httpserver: invalid value %q of field %s: %v", vals[0], name, err)
+		}
+	}
+	return nil
+}
+
+// setValue convert val into type of fv and set it.
+func setValue(fv reflect.Value, val string) error {
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(val)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(val, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(val, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(val, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+	return nil
+}
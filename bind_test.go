@@ -0,0 +1,252 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testSignup struct {
+	Name  string   `json:"name" validate:"required,max=10"`
+	Age   int      `json:"age" validate:"min=18"`
+	Tags  []string `json:"tags" validate:"max=2"`
+	Notes string   `json:"notes"`
+}
+
+func TestBindAndValidate_Valid(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader(`{"name":"gopher","age":20,"tags":["a"]}`))
+	var dst testSignup
+	if err := BindAndValidate(r, &dst); err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	if dst.Name != "gopher" || dst.Age != 20 {
+		t.Errorf("%s expected payload bound, returned %+v", t.Name(), dst)
+	}
+}
+
+func TestBindAndValidate_Invalid(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader(`{"age":12,"tags":["a","b","c"]}`))
+	var dst testSignup
+	err := BindAndValidate(r, &dst)
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("%s expected ValidationErrors, returned %v", t.Name(), err)
+	}
+	expected := ValidationErrors{
+		"name": "is required",
+		"age":  "must be at least 18",
+		"tags": "must be at most 2",
+	}
+	if !reflect.DeepEqual(errs, expected) {
+		t.Errorf("%s expected %v, returned %v", t.Name(), expected, errs)
+	}
+}
+
+func TestBindAndValidate_InvalidJSON(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader(`{"name":`))
+	var dst testSignup
+	err := BindAndValidate(r, &dst)
+	if _, ok := err.(ValidationErrors); err == nil || ok {
+		t.Errorf("%s expected decoding error, returned %v", t.Name(), err)
+	}
+}
+
+type testAddress struct {
+	City string `json:"city" validate:"required"`
+}
+
+type testProfile struct {
+	Name     string       `json:"name" validate:"required"`
+	Address  testAddress  `json:"address"`
+	Billing  *testAddress `json:"billing"`
+	Shipping *testAddress `json:"shipping"`
+}
+
+func TestBindAndValidate_Nested(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/profile", strings.NewReader(`{"name":"gopher","address":{},"billing":{}}`))
+	var dst testProfile
+	err := BindAndValidate(r, &dst)
+	expected := ValidationErrors{
+		"address.city": "is required",
+		"billing.city": "is required",
+	}
+	if errs, ok := err.(ValidationErrors); !ok || !reflect.DeepEqual(errs, expected) {
+		t.Errorf("%s expected %v, returned %v", t.Name(), expected, err)
+	}
+
+	// fields of embedded struct are promoted by json, so are keyed without its name.
+	var embedded struct {
+		testAddress
+	}
+	r = httptest.NewRequest(http.MethodPost, "/profile", strings.NewReader(`{}`))
+	err = BindAndValidate(r, &embedded)
+	expected = ValidationErrors{"city": "is required"}
+	if errs, ok := err.(ValidationErrors); !ok || !reflect.DeepEqual(errs, expected) {
+		t.Errorf("%s expected %v, returned %v", t.Name(), expected, err)
+	}
+}
+
+func TestBindAndValidate_Rules(t *testing.T) {
+	type account struct {
+		Email string `json:"email" validate:"required,email"`
+		Role  string `json:"role" validate:"oneof=admin user"`
+		ID    string `json:"id" validate:"uuid"`
+	}
+	r := httptest.NewRequest(http.MethodPost, "/account", strings.NewReader(`{"email":"gopher@example.com","role":"admin","id":"5f0e2b4c-3c1d-4d6e-9a8b-7c6d5e4f3a2b"}`))
+	var dst account
+	if err := BindAndValidate(r, &dst); err != nil {
+		t.Errorf("%s expected null error, found %v", t.Name(), err)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/account", strings.NewReader(`{"email":"gopher","role":"root","id":"1"}`))
+	err := BindAndValidate(r, &dst)
+	expected := ValidationErrors{
+		"email": "must satisfy email",
+		"role":  "must be one of admin user",
+		"id":    "must satisfy uuid",
+	}
+	if errs, ok := err.(ValidationErrors); !ok || !reflect.DeepEqual(errs, expected) {
+		t.Errorf("%s expected %v, returned %v", t.Name(), expected, err)
+	}
+}
+
+func TestBindAndValidate_InvalidRule(t *testing.T) {
+	tests := []interface{}{
+		&struct {
+			Email string `json:"email" validate:"required,mail"`
+		}{},
+		&struct {
+			Age int `json:"age" validate:"min=ten"`
+		}{},
+		&struct {
+			Address struct {
+				City string `json:"city" validate:"town"`
+			} `json:"address"`
+		}{},
+	}
+	for _, dst := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader(`{}`))
+		err := BindAndValidate(r, dst)
+		if _, ok := err.(ValidationErrors); err == nil || ok || !strings.Contains(err.Error(), "validate rule") {
+			t.Errorf("%s expected invalid rule error for %T, returned %v", t.Name(), dst, err)
+		}
+	}
+}
+
+func TestResponseValidationError(t *testing.T) {
+	srv := New(&Opts{})
+	srv.POST("/signup", func(w http.ResponseWriter, r *http.Request) {
+		var dst testSignup
+		if errs, ok := BindAndValidate(r, &dst).(ValidationErrors); ok {
+			ResponseValidationError(w, errs)
+		}
+	})
+	r := httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader(`{"age":20}`))
+	r.Header.Set("Request-Id", "test-id")
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, r)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusUnprocessableEntity, w.Code)
+	}
+	if w.Header().Get("Request-Id") != "test-id" || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("%s expected Request-Id and json Content-Type, returned %v", t.Name(), w.Header())
+	}
+	var body map[string]map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%s expected json body, found %v", t.Name(), err)
+	}
+	expected := map[string]map[string]string{"errors": {"name": "is required"}}
+	if !reflect.DeepEqual(body, expected) {
+		t.Errorf("%s expected %v, returned %v", t.Name(), expected, body)
+	}
+}
+
+func TestBindAndValidate_ContextCanceled(t *testing.T) {
+	body, bodyWriter := io.Pipe()
+	defer bodyWriter.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	r := httptest.NewRequest(http.MethodPost, "/signup", body).WithContext(ctx)
+
+	go func() {
+		// slow client sending the body partially then stalling.
+		bodyWriter.Write([]byte(`{"name":"go`))
+		cancel()
+	}()
+	var dst testSignup
+	if err := BindAndValidate(r, &dst); err != context.Canceled {
+		t.Errorf("%s expected %v, returned %v", t.Name(), context.Canceled, err)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader(`{"name":`))
+	if err := BindAndValidate(r, &dst); err == nil || err == context.Canceled {
+		t.Errorf("%s expected malformed json error, returned %v", t.Name(), err)
+	}
+}
+
+func TestBindAndValidate_StalledBody(t *testing.T) {
+	result := make(chan error, 1)
+	srv := New(&Opts{})
+	srv.POST("/signup", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 50*time.Millisecond)
+		defer cancel()
+		var dst testSignup
+		result <- BindAndValidate(r.WithContext(ctx), &dst)
+	})
+	if err := srv.Start(); err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	defer srv.server.Close()
+
+	conn, err := net.Dial("tcp", srv.listener.Addr().String())
+	if err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	defer conn.Close()
+	// announce larger body than sent, then stall.
+	fmt.Fprintf(conn, "POST /signup HTTP/1.1\r\nHost: localhost\r\nContent-Length: 100\r\n\r\n{\"name\":\"go")
+
+	select {
+	case err := <-result:
+		if err != context.DeadlineExceeded {
+			t.Errorf("%s expected %v, returned %v", t.Name(), context.DeadlineExceeded, err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("%s expected read of stalled body interrupted", t.Name())
+	}
+}
+
+type testForm struct {
+	Name    string   `form:"name"`
+	Age     int      `form:"age"`
+	Admin   bool     `form:"admin"`
+	Tags    []string `form:"tag"`
+	Ignored string
+}
+
+func TestBindForm(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/signup?age=1", strings.NewReader("name=gopher&age=20&admin=true&tag=a&tag=b&Ignored=x"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var dst testForm
+	if err := BindForm(r, &dst); err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	expected := testForm{Name: "gopher", Age: 20, Admin: true, Tags: []string{"a", "b"}}
+	if !reflect.DeepEqual(dst, expected) {
+		t.Errorf("%s expected %+v, returned %+v", t.Name(), expected, dst)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader("age=old"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := BindForm(r, &dst); err == nil {
+		t.Errorf("%s expected invalid value error, found null", t.Name())
+	}
+}
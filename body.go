@@ -0,0 +1,46 @@
+package httpserver
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// ErrBodyTooLarge returned by ReadBody if request body is larger than the limit.
+var ErrBodyTooLarge = errors.New("httpserver: request body too large")
+
+// ReadBody read request body of r up to max bytes and restore it, so middleware can read the body,
+// e.g. to validate its signature, and the handler still reads it from the start.
+// If the body is larger than max, ErrBodyTooLarge is returned, the body is still restored whole
+// for the caller to decide, e.g. respond 413. max <= 0 means no limit, keep it bounded for untrusted clients.
+func ReadBody(r *http.Request, max int64) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	body := r.Body
+	var reader io.Reader = body
+	if max > 0 {
+		// one more byte to tell body of exactly max bytes from larger one.
+		reader = io.LimitReader(body, max+1)
+	}
+	b, err := ioutil.ReadAll(reader)
+	if err != nil {
+		r.Body = readCloser{io.MultiReader(bytes.NewReader(b), body), body}
+		return nil, err
+	}
+	if max > 0 && int64(len(b)) > max {
+		r.Body = readCloser{io.MultiReader(bytes.NewReader(b), body), body}
+		return nil, ErrBodyTooLarge
+	}
+	body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	return b, nil
+}
+
+// readCloser read from Reader and close Closer, the original body being read through Reader.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
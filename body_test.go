@@ -0,0 +1,51 @@
+package httpserver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadBody(t *testing.T) {
+	var read []byte
+	verify := func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			b, err := ReadBody(r, 1<<10)
+			if err != nil {
+				ResponseStatus(w, http.StatusRequestEntityTooLarge)
+				return
+			}
+			read = b
+			next(w, r)
+		}
+	}
+	srv := New(&Opts{})
+	srv.POST("/webhook", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		ResponseString(w, http.StatusOK, body)
+	}, verify)
+
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"event":"push"}`)))
+	if string(read) != `{"event":"push"}` || w.Body.String() != `{"event":"push"}` {
+		t.Errorf("%s expected body read by both middleware and handler, returned %s %s", t.Name(), read, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(strings.Repeat("a", 1<<10+1))))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusRequestEntityTooLarge, w.Code)
+	}
+}
+
+func TestReadBody_TooLargeRestored(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("abcdef"))
+	if _, err := ReadBody(r, 3); err != ErrBodyTooLarge {
+		t.Errorf("%s expected %v, returned %v", t.Name(), ErrBodyTooLarge, err)
+	}
+	if body, _ := ioutil.ReadAll(r.Body); string(body) != "abcdef" {
+		t.Errorf("%s expected whole body restored, returned %s", t.Name(), body)
+	}
+}
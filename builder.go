@@ -1,24 +1,46 @@
 package httpserver
 
 import (
+	"context"
 	"crypto/tls"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"syscall"
 	"time"
-
-	_router "github.com/julienschmidt/httprouter"
-	_cors "github.com/rs/cors"
 )
 
 type Builder interface {
 	WithIdleTimeout(time.Duration) *ServerBuilder
 	WithCors(*Cors) *ServerBuilder
 	WithLogger() *ServerBuilder
+	WithAccessLogWriter(io.Writer) *ServerBuilder
+	WithErrorLogWriter(io.Writer) *ServerBuilder
 	WithTLS(*tls.Config) *ServerBuilder
 	WithPanicHandler(func(w http.ResponseWriter, r *http.Request, rcv ...interface{})) *ServerBuilder
 	WithNotFoundHandler(http.HandlerFunc) *ServerBuilder
+	WithMethodNotAllowedHandler(http.HandlerFunc) *ServerBuilder
 	WithMiddleware(Middleware) *ServerBuilder
+	WithConnState(func(net.Conn, http.ConnState)) *ServerBuilder
+	WithBaseContext(func(net.Listener) context.Context) *ServerBuilder
+	WithConnContext(func(ctx context.Context, c net.Conn) context.Context) *ServerBuilder
+	WithErrorHandler(ErrorHandler) *ServerBuilder
+	WithDebugPanics() *ServerBuilder
+	WithStripPrefix(string) *ServerBuilder
+	WithMaxURLLength(int) *ServerBuilder
+	WithCleanPath(redirect bool) *ServerBuilder
+	WithInheritFD(int) *ServerBuilder
+	WithDisableRequestID() *ServerBuilder
+	WithMaxMultipartMemory(int64) *ServerBuilder
+	WithHeadForGet() *ServerBuilder
+	WithPreStopDelay(time.Duration) *ServerBuilder
+	WithKeepAlivePeriod(time.Duration) *ServerBuilder
+	WithListenControl(func(network, address string, c syscall.RawConn) error) *ServerBuilder
+	WithLogFile(*LogFileConfig) *ServerBuilder
+	WithNextProtos(...string) *ServerBuilder
+	WithDefaultHeaders(map[string]string) *ServerBuilder
 
 	AddHandler(methodName string, path string, handler http.HandlerFunc, middlewares ...Middleware) *ServerBuilder
 	AddFilesServer(filePath string, rootPath string, middlewares ...Middleware) *ServerBuilder
@@ -31,13 +53,12 @@ type ServerBuilder struct {
 }
 
 func Build(port uint16) *ServerBuilder {
-	return &ServerBuilder{
-		srv: &Server{
-			port:     port,
-			handlers: _router.New(),
-			logger:   log.New(os.Stderr, "", 0),
-		},
+	srv := &Server{
+		port:   port,
+		logger: log.New(os.Stderr, "", 0),
 	}
+	srv.handlers = srv.newRouter()
+	return &ServerBuilder{srv: srv}
 }
 
 func (sb *ServerBuilder) WithIdleTimeout(idleTimeout time.Duration) *ServerBuilder {
@@ -46,27 +67,30 @@ func (sb *ServerBuilder) WithIdleTimeout(idleTimeout time.Duration) *ServerBuild
 }
 
 func (sb *ServerBuilder) WithCors(cors *Cors) *ServerBuilder {
-	sb.srv.cors = _cors.New(_cors.Options{
-		AllowedOrigins:     cors.AllowedOrigins,
-		AllowedMethods:     cors.AllowedMethods,
-		AllowedHeaders:     cors.AllowedHeaders,
-		ExposedHeaders:     cors.ExposedHeaders,
-		MaxAge:             cors.MaxAge,
-		AllowCredentials:   cors.AllowCredentials,
-		OptionsPassthrough: true,
-		Debug:              cors.IsDebug,
-	})
+	sb.srv.cors = newCors(cors)
+	sb.srv.methodCors = newMethodCors(cors)
 	return sb
 }
 
 func (sb *ServerBuilder) WithLogger() *ServerBuilder {
-	w := make(buffer, 10<<20)
-	go write(w)
-	sb.srv.logger = log.New(w, "", 0)
+	sb.srv.logger = sb.srv.asyncLogger()
 	sb.srv.middlewares = append(sb.srv.middlewares, sb.srv.log)
 	return sb
 }
 
+func (sb *ServerBuilder) WithAccessLogWriter(w io.Writer) *ServerBuilder {
+	sb.srv.accessLogger = log.New(w, "", 0)
+	return sb
+}
+
+func (sb *ServerBuilder) WithErrorLogWriter(w io.Writer) *ServerBuilder {
+	if sb.srv.accessLogger == nil {
+		sb.srv.accessLogger = sb.srv.logger
+	}
+	sb.srv.logger = log.New(w, "", 0)
+	return sb
+}
+
 func (sb *ServerBuilder) WithTLS(tls *tls.Config) *ServerBuilder {
 	sb.srv.tls = tls
 	return sb
@@ -78,8 +102,12 @@ func (sb *ServerBuilder) WithPanicHandler(panicHandler PanicHandler) *ServerBuil
 }
 
 func (sb *ServerBuilder) WithNotFoundHandler(notFoundHandlerFunc http.HandlerFunc) *ServerBuilder {
-	var notFoundHandler http.Handler = &notFound{notFoundHandlerFunc}
-	sb.srv.notFoundHandler = notFoundHandler
+	sb.srv.notFoundHandler = &notFound{sb.srv, notFoundHandlerFunc}
+	return sb
+}
+
+func (sb *ServerBuilder) WithMethodNotAllowedHandler(methodNotAllowedHandlerFunc http.HandlerFunc) *ServerBuilder {
+	sb.srv.methodNotAllowedHandler = &notFound{sb.srv, methodNotAllowedHandlerFunc}
 	return sb
 }
 
@@ -88,6 +116,97 @@ func (sb *ServerBuilder) WithMiddleware(middleware Middleware) *ServerBuilder {
 	return sb
 }
 
+func (sb *ServerBuilder) WithConnState(connState func(net.Conn, http.ConnState)) *ServerBuilder {
+	sb.srv.connState = connState
+	return sb
+}
+
+func (sb *ServerBuilder) WithBaseContext(baseContext func(net.Listener) context.Context) *ServerBuilder {
+	sb.srv.baseContext = baseContext
+	return sb
+}
+
+func (sb *ServerBuilder) WithConnContext(connContext func(ctx context.Context, c net.Conn) context.Context) *ServerBuilder {
+	sb.srv.connContext = connContext
+	return sb
+}
+
+func (sb *ServerBuilder) WithErrorHandler(errorHandler ErrorHandler) *ServerBuilder {
+	sb.srv.errorHandler = errorHandler
+	return sb
+}
+
+func (sb *ServerBuilder) WithDebugPanics() *ServerBuilder {
+	sb.srv.debugPanics = true
+	return sb
+}
+
+func (sb *ServerBuilder) WithStripPrefix(prefix string) *ServerBuilder {
+	sb.srv.stripPrefix = prefix
+	return sb
+}
+
+func (sb *ServerBuilder) WithMaxURLLength(maxURLLength int) *ServerBuilder {
+	sb.srv.maxURLLength = maxURLLength
+	return sb
+}
+
+func (sb *ServerBuilder) WithCleanPath(redirect bool) *ServerBuilder {
+	sb.srv.cleanPath = true
+	sb.srv.redirectCleanPath = redirect
+	return sb
+}
+
+func (sb *ServerBuilder) WithInheritFD(fd int) *ServerBuilder {
+	sb.srv.inheritFD = fd
+	return sb
+}
+
+func (sb *ServerBuilder) WithDisableRequestID() *ServerBuilder {
+	sb.srv.disableRequestID = true
+	return sb
+}
+
+func (sb *ServerBuilder) WithMaxMultipartMemory(maxMemory int64) *ServerBuilder {
+	sb.srv.maxMultipartMemory = maxMemory
+	return sb
+}
+
+func (sb *ServerBuilder) WithHeadForGet() *ServerBuilder {
+	sb.srv.headForGet = true
+	return sb
+}
+
+func (sb *ServerBuilder) WithPreStopDelay(preStopDelay time.Duration) *ServerBuilder {
+	sb.srv.preStopDelay = preStopDelay
+	return sb
+}
+
+func (sb *ServerBuilder) WithKeepAlivePeriod(keepAlivePeriod time.Duration) *ServerBuilder {
+	sb.srv.keepAlivePeriod = keepAlivePeriod
+	return sb
+}
+
+func (sb *ServerBuilder) WithListenControl(listenControl func(network, address string, c syscall.RawConn) error) *ServerBuilder {
+	sb.srv.listenControl = listenControl
+	return sb
+}
+
+func (sb *ServerBuilder) WithLogFile(config *LogFileConfig) *ServerBuilder {
+	sb.srv.withLogFile(config)
+	return sb
+}
+
+func (sb *ServerBuilder) WithNextProtos(nextProtos ...string) *ServerBuilder {
+	sb.srv.nextProtos = nextProtos
+	return sb
+}
+
+func (sb *ServerBuilder) WithDefaultHeaders(headers map[string]string) *ServerBuilder {
+	sb.srv.defaultHeaders = copyHeaders(headers)
+	return sb
+}
+
 func (sb *ServerBuilder) AddHandler(methodName string, path string, handler http.HandlerFunc, middlewares ...Middleware) *ServerBuilder {
 	switch methodName {
 	case http.MethodGet:
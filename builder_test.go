@@ -1,12 +1,16 @@
 package httpserver
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"reflect"
+	"syscall"
 	"testing"
 	"time"
 
@@ -106,6 +110,22 @@ func TestWithLogger(t *testing.T) {
 	}
 }
 
+func TestWithAccessLogWriter(t *testing.T) {
+	testSB := Build(port)
+	sb := testSB.WithAccessLogWriter(os.Stdout)
+	if sb.srv.accessLogger == nil || sb.srv.accessLogger.Writer() != os.Stdout {
+		t.Errorf("error: expected access log to stdout")
+	}
+}
+
+func TestWithErrorLogWriter(t *testing.T) {
+	testSB := Build(port)
+	sb := testSB.WithErrorLogWriter(os.Stdout)
+	if sb.srv.logger.Writer() != os.Stdout || sb.srv.accessLogger.Writer() != os.Stderr {
+		t.Errorf("error: expected error log to stdout and access log to stderr")
+	}
+}
+
 func TestWithTLS(t *testing.T) {
 	testSB := Build(port)
 	tls := &tls.Config{}
@@ -141,6 +161,15 @@ func TestWithNotFoundHandler(t *testing.T) {
 	}
 }
 
+func TestWithMethodNotAllowedHandler(t *testing.T) {
+	testSB := Build(port)
+	methodNotAllowedHandler := func(w http.ResponseWriter, r *http.Request) {}
+	sb := testSB.WithMethodNotAllowedHandler(methodNotAllowedHandler)
+	if sb.srv.methodNotAllowedHandler == nil {
+		t.Errorf("error: expected not null")
+	}
+}
+
 func TestWithMiddleware(t *testing.T) {
 	testSB := Build(port)
 	m := func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc { return nil }
@@ -152,6 +181,152 @@ func TestWithMiddleware(t *testing.T) {
 	}
 }
 
+func TestWithConnState(t *testing.T) {
+	testSB := Build(port)
+	sb := testSB.WithConnState(func(c net.Conn, cs http.ConnState) {})
+	if sb.srv.connState == nil {
+		t.Errorf("error: expected not null")
+	}
+}
+
+func TestWithErrorHandler(t *testing.T) {
+	testSB := Build(port)
+	sb := testSB.WithErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {})
+	if sb.srv.errorHandler == nil {
+		t.Errorf("error: expected not null")
+	}
+}
+
+func TestWithDebugPanics(t *testing.T) {
+	testSB := Build(port)
+	sb := testSB.WithDebugPanics()
+	if !sb.srv.debugPanics {
+		t.Errorf("error: expected true")
+	}
+}
+
+func TestWithStripPrefix(t *testing.T) {
+	testSB := Build(port)
+	sb := testSB.WithStripPrefix("/service")
+	if sb.srv.stripPrefix != "/service" {
+		t.Errorf("error: expected %s, got %s", "/service", sb.srv.stripPrefix)
+	}
+}
+
+func TestWithBaseContext(t *testing.T) {
+	testSB := Build(port)
+	sb := testSB.WithBaseContext(func(ln net.Listener) context.Context { return context.Background() })
+	if sb.srv.baseContext == nil {
+		t.Errorf("error: expected not null")
+	}
+}
+
+func TestWithConnContext(t *testing.T) {
+	testSB := Build(port)
+	sb := testSB.WithConnContext(func(ctx context.Context, c net.Conn) context.Context { return ctx })
+	if sb.srv.connContext == nil {
+		t.Errorf("error: expected not null")
+	}
+}
+
+func TestWithMaxURLLength(t *testing.T) {
+	testSB := Build(port)
+	sb := testSB.WithMaxURLLength(100)
+	if sb.srv.maxURLLength != 100 {
+		t.Errorf("error: expected %d, got %d", 100, sb.srv.maxURLLength)
+	}
+}
+
+func TestWithInheritFD(t *testing.T) {
+	testSB := Build(port)
+	sb := testSB.WithInheritFD(3)
+	if sb.srv.inheritFD != 3 {
+		t.Errorf("error: expected %d, got %d", 3, sb.srv.inheritFD)
+	}
+}
+
+func TestWithDisableRequestID(t *testing.T) {
+	testSB := Build(port)
+	sb := testSB.WithDisableRequestID()
+	if !sb.srv.disableRequestID {
+		t.Errorf("error: expected true")
+	}
+}
+
+func TestWithMaxMultipartMemory(t *testing.T) {
+	testSB := Build(port)
+	sb := testSB.WithMaxMultipartMemory(1 << 20)
+	if sb.srv.maxMultipartMemory != 1<<20 {
+		t.Errorf("error: expected %d, got %d", 1<<20, sb.srv.maxMultipartMemory)
+	}
+}
+
+func TestWithHeadForGet(t *testing.T) {
+	testSB := Build(port)
+	sb := testSB.WithHeadForGet()
+	if !sb.srv.headForGet {
+		t.Errorf("error: expected true")
+	}
+}
+
+func TestWithPreStopDelay(t *testing.T) {
+	testSB := Build(port)
+	sb := testSB.WithPreStopDelay(time.Second)
+	if sb.srv.preStopDelay != time.Second {
+		t.Errorf("error: expected %d, got %d", time.Second, sb.srv.preStopDelay)
+	}
+}
+
+func TestWithKeepAlivePeriod(t *testing.T) {
+	testSB := Build(port)
+	sb := testSB.WithKeepAlivePeriod(time.Minute)
+	if sb.srv.keepAlivePeriod != time.Minute {
+		t.Errorf("error: expected %d, got %d", time.Minute, sb.srv.keepAlivePeriod)
+	}
+}
+
+func TestWithListenControl(t *testing.T) {
+	testSB := Build(port)
+	sb := testSB.WithListenControl(func(network, address string, c syscall.RawConn) error { return nil })
+	if sb.srv.listenControl == nil {
+		t.Errorf("error: expected not null")
+	}
+}
+
+func TestWithLogFile(t *testing.T) {
+	testSB := Build(port)
+	sb := testSB.WithLogFile(&LogFileConfig{Path: filepath.Join(t.TempDir(), "server.log")})
+	if _, ok := sb.srv.logger.Writer().(teeWriter); !ok {
+		t.Errorf("error: expected true")
+	}
+}
+
+func TestWithNextProtos(t *testing.T) {
+	testSB := Build(port)
+	sb := testSB.WithNextProtos("http/1.1")
+	if !reflect.DeepEqual(sb.srv.nextProtos, []string{"http/1.1"}) {
+		t.Errorf("error: expected %v, got %v", []string{"http/1.1"}, sb.srv.nextProtos)
+	}
+}
+
+func TestWithDefaultHeaders(t *testing.T) {
+	testSB := Build(port)
+	headers := map[string]string{"Server": "api"}
+	sb := testSB.WithDefaultHeaders(headers)
+	headers["Server"] = "changed"
+	if sb.srv.defaultHeaders["Server"] != "api" {
+		t.Errorf("error: expected %s, got %s", "api", sb.srv.defaultHeaders["Server"])
+	}
+}
+
+func TestWithCleanPath(t *testing.T) {
+	testSB := Build(port)
+	sb := testSB.WithCleanPath(true)
+	if !sb.srv.cleanPath || !sb.srv.redirectCleanPath {
+		t.Errorf("error: expected true")
+	}
+}
+
 func TestAddHandler(t *testing.T) {
 	testSB := Build(port)
 
@@ -0,0 +1,178 @@
+package httpserver
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheStore store of Cache middleware.
+// Implement this to back the cache with other store, e.g. Redis.
+type CacheStore interface {
+	// Get return cached response of key, false if not found or already expired.
+	Get(key string) (*CachedResponse, bool)
+
+	// Set cache response of key for ttl.
+	Set(key string, res *CachedResponse, ttl time.Duration)
+}
+
+// defaultCacheTTL TTL of Cache if not set.
+const defaultCacheTTL = time.Minute
+
+// defaultCacheMaxEntries maximum number of responses kept by NewMemoryCacheStore.
+const defaultCacheMaxEntries = 10000
+
+// CacheOpts options for Cache middleware.
+type CacheOpts struct {
+	// TTL how long a response is cached. If empty then 1 minute.
+	TTL time.Duration
+
+	// Store optional, if empty then in-memory store of up to 10000 responses is used.
+	Store CacheStore
+
+	// KeyFunc optional, if empty then request URI (path and query) is used as key.
+	KeyFunc func(r *http.Request) string
+}
+
+// Cache middleware to cache successful GET responses in the store for opts.TTL and serve
+// subsequent requests from the store until expired. Cached response is served to every client,
+// so only cache responses which are the same for all of them.
+// Response setting cookie, varying by request header (`Vary`) or with header `Cache-Control` of no-store,
+// no-cache or private is not cached, and request with Authorization header bypasses the cache entirely.
+// To cache compressed responses, put Compress before Cache so each client gets its own encoding of the cached body.
+// Header `X-Cache: HIT` or `X-Cache: MISS` is set to the response. opts can be nil to use the defaults.
+func Cache(opts *CacheOpts) Middleware {
+	if opts == nil {
+		opts = &CacheOpts{}
+	}
+	ttl := opts.TTL
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+	store := opts.Store
+	if store == nil {
+		store = NewMemoryCacheStore()
+	}
+	keyFunc := opts.KeyFunc
+	if keyFunc == nil {
+		keyFunc = func(r *http.Request) string {
+			return r.URL.RequestURI()
+		}
+	}
+
+	return func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet || r.Header.Get("Authorization") != "" {
+				next(w, r)
+				return
+			}
+			key := keyFunc(r)
+			if res, ok := store.Get(key); ok {
+				w.Header().Set("X-Cache", "HIT")
+				res.writeTo(w)
+				return
+			}
+
+			rec := newRecorder(w)
+			next(rec, r)
+			res := rec.result()
+			if res.StatusCode == http.StatusOK && cacheable(res.Header) {
+				store.Set(key, res, ttl)
+			}
+			w.Header().Set("X-Cache", "MISS")
+			res.writeTo(w)
+		}
+	}
+}
+
+// cacheable whether response of header may be shared with other clients.
+func cacheable(header http.Header) bool {
+	if len(header.Values("Set-Cookie")) > 0 || len(header.Values("Vary")) > 0 {
+		return false
+	}
+	for _, v := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(v, ",") {
+			switch strings.ToLower(strings.TrimSpace(directive)) {
+			case "no-store", "no-cache", "private":
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// NewMemoryCacheStore return in-memory CacheStore of up to 10000 responses, see NewMemoryCacheStoreSize.
+func NewMemoryCacheStore() CacheStore {
+	return NewMemoryCacheStoreSize(defaultCacheMaxEntries)
+}
+
+// NewMemoryCacheStoreSize return in-memory CacheStore of up to maxEntries responses, so clients requesting
+// distinct URLs can not grow memory without bound. Expired responses are evicted on Get, and all of them
+// once the store is full. If it is still full, the response expiring soonest is evicted.
+func NewMemoryCacheStoreSize(maxEntries int) CacheStore {
+	if maxEntries <= 0 {
+		maxEntries = defaultCacheMaxEntries
+	}
+	return &memoryCacheStore{
+		items:      make(map[string]memoryCacheItem),
+		maxEntries: maxEntries,
+	}
+}
+
+type memoryCacheStore struct {
+	mu         sync.RWMutex
+	items      map[string]memoryCacheItem
+	maxEntries int
+}
+
+type memoryCacheItem struct {
+	res       *CachedResponse
+	expiredAt time.Time
+}
+
+func (m *memoryCacheStore) Get(key string) (*CachedResponse, bool) {
+	m.mu.RLock()
+	item, ok := m.items[key]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(item.expiredAt) {
+		m.mu.Lock()
+		delete(m.items, key)
+		m.mu.Unlock()
+		return nil, false
+	}
+	return item.res, true
+}
+
+func (m *memoryCacheStore) Set(key string, res *CachedResponse, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.items[key]; !ok && len(m.items) >= m.maxEntries {
+		evict(m.items, m.maxEntries)
+	}
+	m.items[key] = memoryCacheItem{res: res, expiredAt: time.Now().Add(ttl)}
+}
+
+// evict remove expired items, or the one expiring soonest if items still hold maxEntries.
+func evict(items map[string]memoryCacheItem, maxEntries int) {
+	now := time.Now()
+	var (
+		soonest   string
+		soonestAt time.Time
+	)
+	for k, item := range items {
+		if now.After(item.expiredAt) {
+			delete(items, k)
+			continue
+		}
+		if soonest == "" || item.expiredAt.Before(soonestAt) {
+			soonest, soonestAt = k, item.expiredAt
+		}
+	}
+	if len(items) >= maxEntries {
+		delete(items, soonest)
+	}
+}
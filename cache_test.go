@@ -0,0 +1,143 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	calls := 0
+	srv := New(&Opts{})
+	srv.GET("/cached", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("X-Custom", "custom")
+		ResponseString(w, http.StatusOK, "cached")
+	}, Cache(&CacheOpts{TTL: time.Minute}))
+
+	w1 := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w1, httptest.NewRequest(http.MethodGet, "/cached", nil))
+	w2 := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w2, httptest.NewRequest(http.MethodGet, "/cached", nil))
+
+	if calls != 1 {
+		t.Errorf("%s expected handler called %d time, returned %d", t.Name(), 1, calls)
+	}
+	if w1.Header().Get("X-Cache") != "MISS" || w2.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("%s expected MISS then HIT, returned %s then %s", t.Name(), w1.Header().Get("X-Cache"), w2.Header().Get("X-Cache"))
+	}
+	if w2.Code != http.StatusOK || w2.Body.String() != "cached" || w2.Header().Get("X-Custom") != "custom" {
+		t.Errorf("%s expected cached response, returned %d %s %v", t.Name(), w2.Code, w2.Body.String(), w2.Header())
+	}
+	if w1.Header().Get("Request-Id") == w2.Header().Get("Request-Id") {
+		t.Errorf("%s expected cached response keeps its own Request-Id", t.Name())
+	}
+}
+
+func TestCache_Expired(t *testing.T) {
+	calls := 0
+	srv := New(&Opts{})
+	srv.GET("/cached", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		ResponseString(w, http.StatusOK, "cached")
+	}, Cache(&CacheOpts{TTL: 10 * time.Millisecond}))
+
+	srv.handlers.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/cached", nil))
+	time.Sleep(20 * time.Millisecond)
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/cached", nil))
+	if calls != 2 || w.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("%s expected handler called %d times, returned %d", t.Name(), 2, calls)
+	}
+}
+
+func TestCache_NoStore(t *testing.T) {
+	calls := 0
+	srv := New(&Opts{})
+	srv.GET("/nostore", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Cache-Control", "no-store")
+		ResponseString(w, http.StatusOK, "fresh")
+	}, Cache(&CacheOpts{TTL: time.Minute}))
+
+	for i := 0; i < 2; i++ {
+		srv.handlers.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nostore", nil))
+	}
+	if calls != 2 {
+		t.Errorf("%s expected handler called %d times, returned %d", t.Name(), 2, calls)
+	}
+}
+
+func TestCache_Private(t *testing.T) {
+	calls := 0
+	srv := New(&Opts{})
+	srv.GET("/me", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch r.URL.Query().Get("case") {
+		case "cookie":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret"})
+		case "private":
+			w.Header().Set("Cache-Control", "max-age=60, private")
+		case "no-cache":
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		ResponseString(w, http.StatusOK, "user data")
+	}, Cache(nil))
+
+	for _, c := range []string{"cookie", "private", "no-cache", "auth"} {
+		for i := 0; i < 2; i++ {
+			r := httptest.NewRequest(http.MethodGet, "/me?case="+c, nil)
+			if c == "auth" {
+				r.Header.Set("Authorization", "Bearer token")
+			}
+			srv.handlers.ServeHTTP(httptest.NewRecorder(), r)
+		}
+	}
+	if calls != 8 {
+		t.Errorf("%s expected handler called %d times, returned %d", t.Name(), 8, calls)
+	}
+}
+
+func TestCache_Vary(t *testing.T) {
+	calls := 0
+	srv := New(&Opts{})
+	srv.GET("/vary", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		ResponseString(w, http.StatusOK, strings.Repeat("compressed ", 100))
+	}, Cache(nil), Compress(nil))
+
+	r1 := httptest.NewRequest(http.MethodGet, "/vary", nil)
+	r1.Header.Set("Accept-Encoding", "gzip")
+	srv.handlers.ServeHTTP(httptest.NewRecorder(), r1)
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/vary", nil))
+
+	if calls != 2 || w.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("%s expected handler called %d times, returned %d", t.Name(), 2, calls)
+	}
+	if w.Header().Get("Content-Encoding") != "" || w.Body.String() != strings.Repeat("compressed ", 100) {
+		t.Errorf("%s expected uncompressed response, returned %s", t.Name(), w.Header().Get("Content-Encoding"))
+	}
+}
+
+func TestMemoryCacheStore_MaxEntries(t *testing.T) {
+	store := NewMemoryCacheStoreSize(2)
+	res := &CachedResponse{StatusCode: http.StatusOK}
+	store.Set("a", res, time.Minute)
+	store.Set("b", res, time.Hour)
+	store.Set("c", res, time.Hour)
+
+	if _, ok := store.Get("a"); ok {
+		t.Errorf("%s expected %s evicted", t.Name(), "a")
+	}
+	for _, key := range []string{"b", "c"} {
+		if _, ok := store.Get(key); !ok {
+			t.Errorf("%s expected %s kept", t.Name(), key)
+		}
+	}
+	if n := len(store.(*memoryCacheStore).items); n != 2 {
+		t.Errorf("%s expected %d entries, returned %d", t.Name(), 2, n)
+	}
+}
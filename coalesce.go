@@ -0,0 +1,95 @@
+package httpserver
+
+import (
+	"net/http"
+	"sync"
+)
+
+// CoalesceOpts options for Coalesce middleware.
+type CoalesceOpts struct {
+	// KeyFunc return key identifying identical requests.
+	// If empty then default is used, which is method, path and raw query.
+	KeyFunc func(r *http.Request) string
+
+	// Methods to be coalesced. If empty then only GET is coalesced.
+	Methods []string
+}
+
+// Coalesce middleware to coalesce concurrent identical requests, so handler runs once and its response is shared
+// with all requests waiting on the same key. Useful to protect expensive handler from cache-miss storm.
+// Only use it on handlers whose response depends on nothing else but the key, e.g. not on user specific data.
+// opts can be nil to use the defaults.
+func Coalesce(opts *CoalesceOpts) Middleware {
+	if opts == nil {
+		opts = &CoalesceOpts{}
+	}
+	keyFunc := opts.KeyFunc
+	if keyFunc == nil {
+		keyFunc = func(r *http.Request) string {
+			return r.Method + " " + r.URL.Path + "?" + r.URL.RawQuery
+		}
+	}
+	methods := map[string]bool{http.MethodGet: true}
+	if len(opts.Methods) > 0 {
+		methods = make(map[string]bool, len(opts.Methods))
+		for _, m := range opts.Methods {
+			methods[m] = true
+		}
+	}
+	group := &flightGroup{}
+
+	return func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if !methods[r.Method] {
+				next(w, r)
+				return
+			}
+			res := group.do(keyFunc(r), func() *CachedResponse {
+				rec := newRecorder(w)
+				next(rec, r)
+				return rec.result()
+			})
+			if res == nil { // the handler running for this key panicked, run it on our own.
+				next(w, r)
+				return
+			}
+			res.writeTo(w)
+		}
+	}
+}
+
+// flightGroup run only one fn at a time for the same key, others wait and share the result.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	wg  sync.WaitGroup
+	res *CachedResponse
+}
+
+func (g *flightGroup) do(key string, fn func() *CachedResponse) *CachedResponse {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.res
+	}
+	c := &flightCall{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		c.wg.Done()
+	}()
+	c.res = fn()
+	return c.res
+}
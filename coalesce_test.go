@@ -0,0 +1,71 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCoalesce(t *testing.T) {
+	var calls int32
+	srv := New(&Opts{})
+	srv.GET("/expensive", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(100 * time.Millisecond)
+		ResponseString(w, http.StatusOK, "expensive")
+	}, Coalesce(nil))
+
+	n := 10
+	var wg sync.WaitGroup
+	recorders := make([]*httptest.ResponseRecorder, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		recorders[i] = httptest.NewRecorder()
+		go func(w *httptest.ResponseRecorder) {
+			defer wg.Done()
+			srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/expensive?q=1", nil))
+		}(recorders[i])
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("%s expected handler called %d time, returned %d", t.Name(), 1, calls)
+	}
+	ids := make(map[string]bool)
+	for _, w := range recorders {
+		if w.Code != http.StatusOK || w.Body.String() != "expensive" {
+			t.Errorf("%s expected %d expensive, returned %d %s", t.Name(), http.StatusOK, w.Code, w.Body.String())
+		}
+		ids[w.Header().Get("Request-Id")] = true
+	}
+	if len(ids) != n {
+		t.Errorf("%s expected %d distinct Request-Id, returned %d", t.Name(), n, len(ids))
+	}
+}
+
+func TestCoalesce_MethodNotCoalesced(t *testing.T) {
+	var calls int32
+	srv := New(&Opts{})
+	srv.POST("/expensive", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(50 * time.Millisecond)
+	}, Coalesce(&CoalesceOpts{
+		KeyFunc: func(r *http.Request) string { return "same" },
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			srv.handlers.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/expensive", nil))
+		}()
+	}
+	wg.Wait()
+	if calls != 3 {
+		t.Errorf("%s expected handler called %d times, returned %d", t.Name(), 3, calls)
+	}
+}
@@ -0,0 +1,242 @@
+package httpserver
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	_brotli "github.com/andybalholm/brotli"
+)
+
+// DefaultCompressContentTypes content types compressed by Compress if CompressOpts.ContentTypes is empty.
+var DefaultCompressContentTypes = []string{"text/*", "application/json", "application/xml", "application/javascript"}
+
+// DefaultCompressEncodings encodings negotiated by Compress in order of preference if CompressOpts.Encodings is empty.
+var DefaultCompressEncodings = []string{"br", "gzip"}
+
+// Encoder create writer compressing into w with an encoding, closing it flushes the compressed stream.
+// Implement this to plug other encoding, e.g. zstd, or other implementation of br and gzip.
+// If the writer has Flush() error, as gzip and brotli writers do, it is called when handler flushes, e.g. streaming response.
+type Encoder func(w io.Writer) io.WriteCloser
+
+// CompressOpts options for Compress middleware.
+type CompressOpts struct {
+	// Level gzip compression level, see compress/gzip. If empty then gzip.DefaultCompression is used.
+	Level int
+
+	// BrotliLevel brotli compression level from 0 to 11. If empty then brotli default level 6 is used.
+	BrotliLevel int
+
+	// ContentTypes compressible content types, entry ending with `/*` matches the whole type, e.g. `text/*`.
+	// Responses of other content types, e.g. images, video and zip which are already compressed, are written as is.
+	// If empty then DefaultCompressContentTypes is used.
+	ContentTypes []string
+
+	// Encodings negotiated encodings in order of preference, earlier one wins if client accepts them equally.
+	// If empty then DefaultCompressEncodings is used.
+	Encodings []string
+
+	// Encoders optional, encoder by encoding name, overriding the built-in br and gzip or adding other encodings
+	// listed in Encodings.
+	Encoders map[string]Encoder
+}
+
+// Compress middleware to compress the response with brotli or gzip, whichever the client prefers by Accept-Encoding,
+// earlier of Encodings on tie, brotli by default, if the response content type is compressible. Otherwise the response is written as is,
+// as well as if the client accepts none of them, i.e. identity.
+// If Content-Type is not set before the first write, it is detected from the written body.
+// opts can be nil to use the defaults.
+func Compress(opts *CompressOpts) Middleware {
+	if opts == nil {
+		opts = &CompressOpts{}
+	}
+	level := opts.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	brotliLevel := opts.BrotliLevel
+	if brotliLevel == 0 {
+		brotliLevel = _brotli.DefaultCompression
+	}
+	contentTypes := opts.ContentTypes
+	if len(contentTypes) == 0 {
+		contentTypes = DefaultCompressContentTypes
+	}
+	encodings := opts.Encodings
+	if len(encodings) == 0 {
+		encodings = DefaultCompressEncodings
+	}
+	encoders := map[string]Encoder{
+		"br": func(w io.Writer) io.WriteCloser {
+			return _brotli.NewWriterLevel(w, brotliLevel)
+		},
+		"gzip": func(w io.Writer) io.WriteCloser {
+			gz, err := gzip.NewWriterLevel(w, level)
+			if err != nil {
+				gz = gzip.NewWriter(w)
+			}
+			return gz
+		},
+	}
+	for name, encoder := range opts.Encoders {
+		encoders[name] = encoder
+	}
+	for _, name := range encodings {
+		if encoders[name] == nil {
+			panic("httpserver: no encoder for encoding " + name)
+		}
+	}
+
+	return func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), encodings)
+			if encoding == "" {
+				next(w, r)
+				return
+			}
+			cw := &compressWriter{
+				ResponseWriter: w,
+				encoding:       encoding,
+				encoder:        encoders[encoding],
+				contentTypes:   contentTypes,
+				statusCode:     http.StatusOK,
+			}
+			next(cw, r)
+			// not deferred, on panic nothing is committed yet so recoverPanic can still respond with 500.
+			cw.close()
+		}
+	}
+}
+
+// negotiateEncoding return encoding from supported with the highest q value in Accept-Encoding header value,
+// earlier entry of supported wins on tie. Empty if none of supported is accepted.
+func negotiateEncoding(acceptEncoding string, supported []string) string {
+	accepted := make(map[string]float64)
+	for _, v := range strings.Split(acceptEncoding, ",") {
+		parts := strings.Split(v, ";")
+		name := strings.TrimSpace(parts[0])
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		for _, p := range parts[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				if f, err := strconv.ParseFloat(p[2:], 64); err == nil {
+					q = f
+				}
+			}
+		}
+		accepted[name] = q
+	}
+
+	var (
+		best  string
+		bestQ float64
+	)
+	for _, enc := range supported {
+		q, ok := accepted[enc]
+		if !ok {
+			q, ok = accepted["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+// compressible check whether contentType matches one of contentTypes.
+func compressible(contentType string, contentTypes []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, v := range contentTypes {
+		if v == mediaType || (strings.HasSuffix(v, "/*") && strings.HasPrefix(mediaType, v[:len(v)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// compressWriter delay writing header until the first write, so it can decide whether to compress
+// based on the final headers.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding     string
+	encoder      Encoder
+	contentTypes []string
+
+	statusCode    int
+	headerWritten bool
+	enc           io.WriteCloser
+}
+
+func (cw *compressWriter) WriteHeader(statusCode int) {
+	if cw.headerWritten {
+		return
+	}
+	cw.statusCode = statusCode
+	if statusCode < http.StatusOK || statusCode == http.StatusNoContent || statusCode == http.StatusNotModified {
+		cw.writeHeader(false)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.headerWritten {
+		h := cw.Header()
+		if h.Get("Content-Type") == "" {
+			h.Set("Content-Type", http.DetectContentType(p))
+		}
+		cw.writeHeader(h.Get("Content-Encoding") == "" && compressible(h.Get("Content-Type"), cw.contentTypes))
+	}
+	if cw.enc != nil {
+		return cw.enc.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// Flush write the pending header, flush the compressed stream so far, then the wrapped writer if it is http.Flusher.
+func (cw *compressWriter) Flush() {
+	if !cw.headerWritten {
+		h := cw.Header()
+		cw.writeHeader(h.Get("Content-Encoding") == "" && compressible(h.Get("Content-Type"), cw.contentTypes))
+	}
+	if f, ok := cw.enc.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressWriter) writeHeader(compress bool) {
+	cw.headerWritten = true
+	if compress {
+		h := cw.Header()
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		cw.enc = cw.encoder(cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.statusCode)
+}
+
+// close flush the compressed stream, or write the pending header if nothing has been written.
+func (cw *compressWriter) close() {
+	if !cw.headerWritten {
+		cw.writeHeader(false)
+	}
+	if cw.enc != nil {
+		cw.enc.Close()
+	}
+}
+
+// Unwrap return the wrapped http.ResponseWriter.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
@@ -0,0 +1,252 @@
+package httpserver
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	_brotli "github.com/andybalholm/brotli"
+)
+
+var pngHeader = []byte("\x89PNG\x0D\x0A\x1A\x0A\x00\x00\x00\x0DIHDR")
+
+func newCompressServer(opts *CompressOpts) *Server {
+	srv := New(&Opts{})
+	srv.GET("/json", func(w http.ResponseWriter, r *http.Request) {
+		ResponseJSON(w, http.StatusOK, map[string]string{"key": "value"})
+	}, Compress(opts))
+	srv.GET("/png", func(w http.ResponseWriter, r *http.Request) {
+		Response(w, http.StatusOK, pngHeader)
+	}, Compress(opts))
+	return srv
+}
+
+func TestCompress_JSON(t *testing.T) {
+	srv := newCompressServer(nil)
+	r := httptest.NewRequest(http.MethodGet, "/json", nil)
+	r.Header.Set("Accept-Encoding", "gzip, deflate")
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, r)
+
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("%s expected gzip, returned %s", t.Name(), w.Header().Get("Content-Encoding"))
+	}
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	body, _ := ioutil.ReadAll(gz)
+	if string(body) != `{"key":"value"}`+"\n" {
+		t.Errorf("%s expected json body, returned %s", t.Name(), body)
+	}
+	if w.Header().Get("Request-Id") == "" {
+		t.Errorf("%s expected Header Request-Id not empty, found empty", t.Name())
+	}
+}
+
+func TestCompress_Panic(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GET("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("compress")
+	}, Compress(nil))
+	r := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, r)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusInternalServerError, w.Code)
+	}
+	if w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("%s expected no Content-Encoding, returned %s", t.Name(), w.Header().Get("Content-Encoding"))
+	}
+}
+
+func TestCompress_SkipPNG(t *testing.T) {
+	srv := newCompressServer(nil)
+	r := httptest.NewRequest(http.MethodGet, "/png", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, r)
+
+	if w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("%s expected not compressed, returned %s", t.Name(), w.Header().Get("Content-Encoding"))
+	}
+	if w.Header().Get("Content-Type") != "image/png" || w.Body.String() != string(pngHeader) {
+		t.Errorf("%s expected png body as is, returned %s %q", t.Name(), w.Header().Get("Content-Type"), w.Body.String())
+	}
+}
+
+func TestCompress_ConfiguredContentTypes(t *testing.T) {
+	srv := newCompressServer(&CompressOpts{ContentTypes: []string{"image/*"}})
+	for path, encoding := range map[string]string{"/png": "gzip", "/json": ""} {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		srv.handlers.ServeHTTP(w, r)
+		if w.Header().Get("Content-Encoding") != encoding {
+			t.Errorf("%s %s expected %q, returned %q", t.Name(), path, encoding, w.Header().Get("Content-Encoding"))
+		}
+	}
+}
+
+func TestCompress_NotAccepted(t *testing.T) {
+	srv := newCompressServer(nil)
+	for _, acceptEncoding := range []string{"", "gzip;q=0", "deflate"} {
+		r := httptest.NewRequest(http.MethodGet, "/json", nil)
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+		w := httptest.NewRecorder()
+		srv.handlers.ServeHTTP(w, r)
+		if w.Header().Get("Content-Encoding") != "" || w.Body.String() != `{"key":"value"}`+"\n" {
+			t.Errorf("%s expected not compressed, returned %s", t.Name(), w.Header().Get("Content-Encoding"))
+		}
+	}
+}
+
+func TestCompress_Brotli(t *testing.T) {
+	srv := newCompressServer(nil)
+	r := httptest.NewRequest(http.MethodGet, "/json", nil)
+	r.Header.Set("Accept-Encoding", "br")
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, r)
+
+	if w.Header().Get("Content-Encoding") != "br" {
+		t.Fatalf("%s expected br, returned %s", t.Name(), w.Header().Get("Content-Encoding"))
+	}
+	if !reflect.DeepEqual(w.Header()["Vary"], []string{"Accept-Encoding"}) {
+		t.Errorf("%s expected Vary Accept-Encoding, returned %v", t.Name(), w.Header()["Vary"])
+	}
+	body, err := ioutil.ReadAll(_brotli.NewReader(w.Body))
+	if err != nil {
+		t.Fatalf("%s expected valid brotli stream, found %v", t.Name(), err)
+	}
+	if string(body) != `{"key":"value"}`+"\n" {
+		t.Errorf("%s expected json body, returned %s", t.Name(), body)
+	}
+}
+
+func TestCompress_Encodings(t *testing.T) {
+	srv := newCompressServer(&CompressOpts{Encodings: []string{"gzip", "br"}})
+	r := httptest.NewRequest(http.MethodGet, "/json", nil)
+	r.Header.Set("Accept-Encoding", "br, gzip")
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, r)
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("%s expected gzip preferred, returned %s", t.Name(), w.Header().Get("Content-Encoding"))
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/json", nil)
+	r.Header.Set("Accept-Encoding", "br")
+	w = httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, r)
+	if w.Header().Get("Content-Encoding") != "br" {
+		t.Errorf("%s expected br, returned %s", t.Name(), w.Header().Get("Content-Encoding"))
+	}
+}
+
+type upperEncoder struct {
+	w io.Writer
+}
+
+func (u upperEncoder) Write(p []byte) (int, error) {
+	return u.w.Write(bytes.ToUpper(p))
+}
+
+func (u upperEncoder) Close() error {
+	return nil
+}
+
+func TestCompress_Encoders(t *testing.T) {
+	srv := newCompressServer(&CompressOpts{
+		Encodings: []string{"upper", "gzip"},
+		Encoders: map[string]Encoder{
+			"upper": func(w io.Writer) io.WriteCloser { return upperEncoder{w} },
+		},
+	})
+	r := httptest.NewRequest(http.MethodGet, "/json", nil)
+	r.Header.Set("Accept-Encoding", "gzip, upper")
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, r)
+	if w.Header().Get("Content-Encoding") != "upper" || w.Body.String() != `{"KEY":"VALUE"}`+"\n" {
+		t.Errorf("%s expected custom encoding, returned %s %s", t.Name(), w.Header().Get("Content-Encoding"), w.Body.String())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%s expected panic for encoding without encoder", t.Name())
+		}
+	}()
+	Compress(&CompressOpts{Encodings: []string{"zstd"}})
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	supported := []string{"br", "gzip"}
+	tests := map[string]string{
+		"gzip, deflate, br":  "br",
+		"gzip;q=1, br;q=0.5": "gzip",
+		"br;q=0, gzip":       "gzip",
+		"*":                  "br",
+		"identity":           "",
+		"":                   "",
+	}
+	for acceptEncoding, expected := range tests {
+		if enc := negotiateEncoding(acceptEncoding, supported); enc != expected {
+			t.Errorf("%s %q expected %q, returned %q", t.Name(), acceptEncoding, expected, enc)
+		}
+	}
+}
+
+func TestCompress_StreamThroughTimeout(t *testing.T) {
+	next := make(chan struct{})
+	srv := New(&Opts{})
+	srv.GET("/stream", func(w http.ResponseWriter, r *http.Request) {
+		items := make(chan interface{})
+		go func() {
+			defer close(items)
+			items <- map[string]int{"id": 0}
+			<-next // sent only once the client has received the first item.
+			items <- map[string]int{"id": 1}
+		}()
+		ResponseNDJSON(w, http.StatusCreated, items)
+	}, Compress(&CompressOpts{ContentTypes: []string{"application/x-ndjson"}, Encodings: []string{"gzip"}}), Timeout(time.Second))
+	ts := srv.TestServer()
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/stream")
+	if err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || !resp.Uncompressed || resp.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Errorf("%s expected gzipped ndjson %d, returned %d %v %v", t.Name(), http.StatusCreated, resp.StatusCode, resp.Uncompressed, resp.Header)
+	}
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	for i, expected := range []string{`{"id":0}`, `{"id":1}`} {
+		select {
+		case line := <-lines:
+			if line != expected {
+				t.Errorf("%s expected %s, returned %s", t.Name(), expected, line)
+			}
+		case <-time.After(500 * time.Millisecond):
+			t.Fatalf("%s expected item %d flushed to the client", t.Name(), i)
+		}
+		if i == 0 {
+			close(next)
+		}
+	}
+}
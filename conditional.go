@@ -0,0 +1,81 @@
+package httpserver
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CheckPreconditions evaluate conditional request headers of r against current etag and modTime of the resource,
+// e.g. for optimistic concurrency on PUT and PATCH or caching on GET, in order of RFC 7232:
+// If-Match, If-Unmodified-Since, If-None-Match then If-Modified-Since.
+// It responds 412 Precondition Failed, or 304 Not Modified for GET and HEAD, and returns true if handler should stop.
+// Empty etag or zero modTime skip the conditions depending on them.
+// etag is quoted, optionally weak, e.g. `"v1"` or `W/"v1"`.
+func CheckPreconditions(w http.ResponseWriter, r *http.Request, etag string, modTime time.Time) (done bool) {
+	modTime = modTime.Truncate(time.Second)
+	isGetOrHead := r.Method == http.MethodGet || r.Method == http.MethodHead
+
+	if im := r.Header.Get("If-Match"); im != "" {
+		if !matchETag(im, etag, false) {
+			ResponseStatus(w, http.StatusPreconditionFailed)
+			return true
+		}
+	} else if ius, err := http.ParseTime(r.Header.Get("If-Unmodified-Since")); err == nil && !modTime.IsZero() {
+		if modTime.After(ius) {
+			ResponseStatus(w, http.StatusPreconditionFailed)
+			return true
+		}
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if matchETag(inm, etag, true) {
+			if isGetOrHead {
+				notModified(w, etag, modTime)
+			} else {
+				ResponseStatus(w, http.StatusPreconditionFailed)
+			}
+			return true
+		}
+	} else if ims, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && isGetOrHead && !modTime.IsZero() {
+		if !modTime.After(ims) {
+			notModified(w, etag, modTime)
+			return true
+		}
+	}
+	return false
+}
+
+func notModified(w http.ResponseWriter, etag string, modTime time.Time) {
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	if !modTime.IsZero() {
+		w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	}
+	ResponseStatus(w, http.StatusNotModified)
+}
+
+// matchETag report whether etag matches one of comma separated etags of header, or header is `*` and etag exists.
+// Weak comparison ignores W/ prefix, strong comparison never matches weak etag.
+func matchETag(header string, etag string, weak bool) bool {
+	if etag == "" {
+		return false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+			continue
+		}
+		if !strings.HasPrefix(candidate, "W/") && !strings.HasPrefix(etag, "W/") && candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckPreconditions(t *testing.T) {
+	modTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		method   string
+		header   string
+		value    string
+		done     bool
+		expected int
+	}{
+		{http.MethodPut, "If-Match", `"v1"`, false, http.StatusOK},
+		{http.MethodPut, "If-Match", `"v0", "v1"`, false, http.StatusOK},
+		{http.MethodPut, "If-Match", `"v0"`, true, http.StatusPreconditionFailed},
+		{http.MethodPut, "If-Match", `W/"v1"`, true, http.StatusPreconditionFailed},
+		{http.MethodPut, "If-Match", `*`, false, http.StatusOK},
+		{http.MethodPut, "If-Unmodified-Since", modTime.Add(-time.Hour).Format(http.TimeFormat), true, http.StatusPreconditionFailed},
+		{http.MethodPut, "If-Unmodified-Since", modTime.Format(http.TimeFormat), false, http.StatusOK},
+		{http.MethodGet, "If-None-Match", `W/"v1"`, true, http.StatusNotModified},
+		{http.MethodGet, "If-None-Match", `"v0"`, false, http.StatusOK},
+		{http.MethodPut, "If-None-Match", `*`, true, http.StatusPreconditionFailed},
+		{http.MethodGet, "If-Modified-Since", modTime.Format(http.TimeFormat), true, http.StatusNotModified},
+		{http.MethodGet, "If-Modified-Since", modTime.Add(-time.Hour).Format(http.TimeFormat), false, http.StatusOK},
+	}
+	for _, test := range tests {
+		var done bool
+		srv := New(&Opts{})
+		srv.Method(test.method, "/doc", func(w http.ResponseWriter, r *http.Request) {
+			if done = CheckPreconditions(w, r, `"v1"`, modTime); done {
+				return
+			}
+			ResponseStatus(w, http.StatusOK)
+		})
+		r := httptest.NewRequest(test.method, "/doc", nil)
+		r.Header.Set(test.header, test.value)
+		w := httptest.NewRecorder()
+		srv.handlers.ServeHTTP(w, r)
+		if done != test.done || w.Code != test.expected {
+			t.Errorf("%s %s %s: %s expected %v %d, returned %v %d", t.Name(), test.method, test.header, test.value, test.done, test.expected, done, w.Code)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package httpserver
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"os"
+)
+
+// contextKey type of keys of values stored by this package into request context.
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	serverKey
+	routeKey
+	groupKey
+	connKey
+)
+
+// RequestIDFromContext return request id carried by ctx, either from r.Context() of handled request
+// or from context returned by CopyRequestContext. Empty if none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// CopyRequestContext return new context carrying only the request id of r, to be passed into
+// goroutines spawned by handler so they can log with the same correlation id.
+// It does not carry r's cancellation and deadline, since r's context is cancelled as soon as handler returns,
+// which is usually not wanted for background work. Derive own timeout from it if needed.
+func CopyRequestContext(r *http.Request) context.Context {
+	id := RequestIDFromContext(r.Context())
+	if id == "" {
+		id = r.Header.Get("Request-Id")
+	}
+	return context.WithValue(context.Background(), requestIDKey, id)
+}
+
+// Logger return logger tagged with the request id of r, each line is prefixed with `[request-id] `.
+// It writes through the server logger, or stderr if r is not handled by the server.
+func Logger(r *http.Request) *log.Logger {
+	prefix := "[" + RequestIDFromContext(r.Context()) + "] "
+	if s, ok := serverFromContext(r.Context()); ok {
+		return log.New(s.logger.Writer(), prefix, s.logger.Flags())
+	}
+	return log.New(os.Stderr, prefix, 0)
+}
+
+// RoutePattern return pattern of the route matched by r as registered, e.g. /users/:id for /users/7,
+// useful to group logs and metrics per route. Empty if r is not served by registered route.
+func RoutePattern(r *http.Request) string {
+	route, _ := r.Context().Value(routeKey).(string)
+	return route
+}
+
+// GroupPrefix return full prefix of the group whose route matched r, e.g. /api/v1, useful to build links
+// to sibling routes. Empty if the route is not registered in a group. Set right before handler,
+// so it is not available to middlewares.
+func GroupPrefix(r *http.Request) string {
+	prefix, _ := r.Context().Value(groupKey).(string)
+	return prefix
+}
+
+// SetContextValue return shallow copy of r carrying val under key in its context,
+// used by middleware to pass values into handler, e.g. authenticated user:
+//
+//	type ctxKey int
+//
+//	const userKey ctxKey = iota
+//
+//	next(w, httpserver.SetContextValue(r, userKey, user))
+//
+// Key should be of unexported type defined by the package setting it, never built-in type like string,
+// as keys of the same type and value collide across packages.
+func SetContextValue(r *http.Request, key, val interface{}) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), key, val))
+}
+
+// ContextValue return value under key set by SetContextValue into context of r, nil if none.
+func ContextValue(r *http.Request, key interface{}) interface{} {
+	return r.Context().Value(key)
+}
+
+// withServer put the server into request context, so helpers called by handler can read server configuration.
+func (s *Server) withServer(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		next(w, r.WithContext(context.WithValue(r.Context(), serverKey, s)))
+	}
+}
+
+// withConn put connection c into its context, so BindAndValidate can interrupt read of stalled body,
+// then pass the context into ConnContext if any.
+func (s *Server) withConn(ctx context.Context, c net.Conn) context.Context {
+	ctx = context.WithValue(ctx, connKey, c)
+	if s.connContext != nil {
+		return s.connContext(ctx, c)
+	}
+	return ctx
+}
+
+// serverFromContext return server handling the request of ctx, if any.
+func serverFromContext(ctx context.Context) (*Server, bool) {
+	s, ok := ctx.Value(serverKey).(*Server)
+	return s, ok
+}
+
+// ClientGone return channel closed once client of r has gone away, or r is otherwise cancelled,
+// so handler writing large response can stop early.
+// Request context is always derived from the incoming one, cancellation is never swallowed.
+func ClientGone(r *http.Request) <-chan struct{} {
+	return r.Context().Done()
+}
@@ -0,0 +1,201 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCopyRequestContext(t *testing.T) {
+	var (
+		handlerID string
+		ctx       context.Context
+	)
+	srv := New(&Opts{})
+	srv.GET("/async", func(w http.ResponseWriter, r *http.Request) {
+		handlerID = RequestIDFromContext(r.Context())
+		ctx = CopyRequestContext(r)
+	})
+	r := httptest.NewRequest(http.MethodGet, "/async", nil)
+	r.Header.Set("Request-Id", "test-id")
+	srv.handlers.ServeHTTP(httptest.NewRecorder(), r)
+
+	if handlerID != "test-id" {
+		t.Errorf("%s expected %s, returned %s", t.Name(), "test-id", handlerID)
+	}
+	if id := RequestIDFromContext(ctx); id != "test-id" {
+		t.Errorf("%s expected %s, returned %s", t.Name(), "test-id", id)
+	}
+	if ctx.Done() != nil || ctx.Err() != nil {
+		t.Errorf("%s expected copied context not cancellable", t.Name())
+	}
+}
+
+func TestDisableRequestID(t *testing.T) {
+	var ids []string
+	srv := New(&Opts{
+		DisableRequestID: true,
+		NotFoundHandler: func(w http.ResponseWriter, r *http.Request) {
+			ResponseString(w, http.StatusNotFound, "not found")
+		},
+	})
+	srv.GET("/id", func(w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, RequestIDFromContext(r.Context()))
+	})
+
+	srv.handlers.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/id", nil))
+	r := httptest.NewRequest(http.MethodGet, "/id", nil)
+	r.Header.Set("X-Request-Id", "gateway-id")
+	srv.handlers.ServeHTTP(httptest.NewRecorder(), r)
+
+	if len(ids) != 2 || ids[0] != "" || ids[1] != "gateway-id" {
+		t.Errorf("%s expected %q, returned %q", t.Name(), []string{"", "gateway-id"}, ids)
+	}
+
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if w.Code != http.StatusNotFound || w.Header().Get("Request-Id") != "" || w.Header().Get("X-Request-Id") != "" {
+		t.Errorf("%s expected 404 without request id, returned %d %v", t.Name(), w.Code, w.Header())
+	}
+}
+
+func TestClientGone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	gone := make(chan bool)
+	srv := New(&Opts{})
+	srv.GET("/large", func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		select {
+		case <-ClientGone(r):
+			gone <- true
+		case <-time.After(time.Second):
+			gone <- false
+		}
+	})
+	r := httptest.NewRequest(http.MethodGet, "/large", nil).WithContext(ctx)
+	go srv.handlers.ServeHTTP(httptest.NewRecorder(), r)
+
+	if !<-gone {
+		t.Errorf("%s expected channel closed after request cancelled", t.Name())
+	}
+}
+
+type testContextKey string
+
+func TestBaseContext(t *testing.T) {
+	srv := New(&Opts{
+		BaseContext: func(ln net.Listener) context.Context {
+			return context.WithValue(context.Background(), testContextKey("db"), "pool")
+		},
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return context.WithValue(ctx, testContextKey("conn"), "conn")
+		},
+	})
+	srv.GET("/shared", func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusOK, fmt.Sprintf("%v %v", r.Context().Value(testContextKey("db")), r.Context().Value(testContextKey("conn"))))
+	})
+	if err := srv.Start(); err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	defer srv.server.Close()
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/shared", srv.listener.Addr()))
+	if err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "pool conn" {
+		t.Errorf("%s expected %s, returned %s", t.Name(), "pool conn", body)
+	}
+}
+
+// lockedBuffer bytes.Buffer safe for concurrent writes.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (lb *lockedBuffer) Write(p []byte) (int, error) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.buf.Write(p)
+}
+
+func TestLogger(t *testing.T) {
+	var out lockedBuffer
+	srv := New(&Opts{})
+	srv.logger = log.New(&out, "", 0)
+	srv.GET("/log", func(w http.ResponseWriter, r *http.Request) {
+		Logger(r).Printf("handling %s", r.URL.Query().Get("id"))
+	})
+
+	var wg sync.WaitGroup
+	for _, id := range []string{"req-1", "req-2"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			r := httptest.NewRequest(http.MethodGet, "/log?id="+id, nil)
+			r.Header.Set("Request-Id", id)
+			srv.handlers.ServeHTTP(httptest.NewRecorder(), r)
+		}(id)
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSpace(out.buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("%s expected %d lines, returned %q", t.Name(), 2, lines)
+	}
+	for _, line := range lines {
+		if line != "[req-1] handling req-1" && line != "[req-2] handling req-2" {
+			t.Errorf("%s expected line prefixed with its own request id, returned %s", t.Name(), line)
+		}
+	}
+}
+
+func TestSetContextValue(t *testing.T) {
+	type ctxKey int
+	const userKey ctxKey = iota
+	auth := func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			next(w, SetContextValue(r, userKey, "gopher"))
+		}
+	}
+	var user interface{}
+	srv := New(&Opts{})
+	srv.GET("/me", func(w http.ResponseWriter, r *http.Request) {
+		user = ContextValue(r, userKey)
+	}, auth)
+	srv.handlers.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/me", nil))
+	if user != "gopher" {
+		t.Errorf("%s expected %v, returned %v", t.Name(), "gopher", user)
+	}
+	if v := ContextValue(httptest.NewRequest(http.MethodGet, "/me", nil), userKey); v != nil {
+		t.Errorf("%s expected nil, returned %v", t.Name(), v)
+	}
+}
+
+func TestGroupPrefixFromContext(t *testing.T) {
+	var prefix, root string
+	srv := New(&Opts{})
+	srv.Group("/api/v1").GET("/users", func(w http.ResponseWriter, r *http.Request) {
+		prefix = GroupPrefix(r)
+	})
+	srv.GET("/root", func(w http.ResponseWriter, r *http.Request) {
+		root = GroupPrefix(r)
+	})
+	srv.handlers.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/users", nil))
+	srv.handlers.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/root", nil))
+	if prefix != "/api/v1" || root != "" {
+		t.Errorf("%s expected %q and empty, returned %q and %q", t.Name(), "/api/v1", prefix, root)
+	}
+}
@@ -0,0 +1,63 @@
+package httpserver
+
+import (
+	"net/http"
+
+	_cors "github.com/rs/cors"
+)
+
+// newCors build cors handler from c, nil if c is nil.
+func newCors(c *Cors) *_cors.Cors {
+	if c == nil {
+		return nil
+	}
+	return _cors.New(_cors.Options{
+		AllowedOrigins:     c.AllowedOrigins,
+		AllowedMethods:     c.AllowedMethods,
+		AllowedHeaders:     c.AllowedHeaders,
+		ExposedHeaders:     c.ExposedHeaders,
+		MaxAge:             c.MaxAge,
+		AllowCredentials:   c.AllowCredentials,
+		OptionsPassthrough: true,
+		Debug:              c.IsDebug,
+	})
+}
+
+// newMethodCors build cors handler of each method in c.MethodCors, nil if there is none.
+func newMethodCors(c *Cors) map[string]*_cors.Cors {
+	if c == nil || len(c.MethodCors) == 0 {
+		return nil
+	}
+	m := make(map[string]*_cors.Cors, len(c.MethodCors))
+	for method, mc := range c.MethodCors {
+		m[method] = newCors(mc)
+	}
+	return m
+}
+
+// corsHandler apply cors of the request method if configured in MethodCors, otherwise the default cors.
+// Method of preflight request is the one in Access-Control-Request-Method header.
+func (s *Server) corsHandler(next http.Handler) http.Handler {
+	var defaultHandler http.Handler = next
+	if s.cors != nil {
+		defaultHandler = s.cors.Handler(next)
+	}
+	if len(s.methodCors) == 0 {
+		return defaultHandler
+	}
+	handlers := make(map[string]http.Handler, len(s.methodCors))
+	for method, c := range s.methodCors {
+		handlers[method] = c.Handler(next)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method := r.Method
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			method = r.Header.Get("Access-Control-Request-Method")
+		}
+		if h, ok := handlers[method]; ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		defaultHandler.ServeHTTP(w, r)
+	})
+}
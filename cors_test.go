@@ -0,0 +1,43 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMethodCors(t *testing.T) {
+	srv := New(&Opts{
+		Cors: &Cors{
+			MethodCors: map[string]*Cors{
+				http.MethodGet:  {AllowedOrigins: []string{"*"}},
+				http.MethodPost: {AllowedOrigins: []string{"https://admin.example.com"}, AllowedMethods: []string{http.MethodPost}},
+			},
+		},
+	})
+	srv.GET("/items", okHandler)
+	srv.POST("/items", okHandler)
+
+	tests := []struct {
+		method, preflightMethod, origin, expected string
+	}{
+		{http.MethodGet, "", "https://other.example.com", "*"},
+		{http.MethodPost, "", "https://other.example.com", ""},
+		{http.MethodPost, "", "https://admin.example.com", "https://admin.example.com"},
+		{http.MethodOptions, http.MethodGet, "https://other.example.com", "*"},
+		{http.MethodOptions, http.MethodPost, "https://other.example.com", ""},
+		{http.MethodOptions, http.MethodPost, "https://admin.example.com", "https://admin.example.com"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/items", nil)
+		r.Header.Set("Origin", tt.origin)
+		if tt.preflightMethod != "" {
+			r.Header.Set("Access-Control-Request-Method", tt.preflightMethod)
+		}
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, r)
+		if allowed := w.Header().Get("Access-Control-Allow-Origin"); allowed != tt.expected {
+			t.Errorf("%s %s %s from %s expected %q, returned %q", t.Name(), tt.method, tt.preflightMethod, tt.origin, tt.expected, allowed)
+		}
+	}
+}
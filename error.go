@@ -0,0 +1,57 @@
+package httpserver
+
+import (
+	"net/http"
+)
+
+// HandlerFuncE handler which returns error.
+// Non-nil error is passed into ErrorHandler to write the error response.
+// Convert it into http.HandlerFunc via Server.Wrap or Group.Wrap.
+type HandlerFuncE func(w http.ResponseWriter, r *http.Request) error
+
+// ErrorHandler write response for error returned from HandlerFuncE.
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+// defaultErrorHandler used if no ErrorHandler is set, error message is not exposed to client.
+func defaultErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	ResponseString(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+}
+
+// Wrap convert handler into http.HandlerFunc, error returned is handled by server error handler.
+func (s *Server) Wrap(handler HandlerFuncE) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := handler(w, r); err != nil {
+			s.getErrorHandler()(w, r, err)
+		}
+	}
+}
+
+func (s *Server) getErrorHandler() ErrorHandler {
+	if s.errorHandler != nil {
+		return s.errorHandler
+	}
+	return defaultErrorHandler
+}
+
+// SetErrorHandler override server error handler for handlers wrapped by this group's Wrap.
+// Precedence: group error handler, then server error handler, then default which responds 500.
+func (g *Group) SetErrorHandler(errorHandler ErrorHandler) {
+	g.errorHandler = errorHandler
+}
+
+// Wrap convert handler into http.HandlerFunc, error returned is handled by group error handler if set,
+// otherwise by server error handler.
+func (g *Group) Wrap(handler HandlerFuncE) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := handler(w, r); err != nil {
+			g.getErrorHandler()(w, r, err)
+		}
+	}
+}
+
+func (g *Group) getErrorHandler() ErrorHandler {
+	if g.errorHandler != nil {
+		return g.errorHandler
+	}
+	return g.server.getErrorHandler()
+}
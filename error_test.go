@@ -0,0 +1,63 @@
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWrap_ErrorHandlerPrecedence(t *testing.T) {
+	failing := func(w http.ResponseWriter, r *http.Request) error {
+		return fmt.Errorf("failed")
+	}
+	srv := New(&Opts{
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			ResponseString(w, http.StatusInternalServerError, "<h1>"+err.Error()+"</h1>")
+		},
+	})
+	api := srv.Group("/api")
+	api.SetErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+		ResponseJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	})
+	web := srv.Group("/web")
+	srv.GET("/root", srv.Wrap(failing))
+	api.GET("/x", api.Wrap(failing))
+	web.GET("/x", web.Wrap(failing))
+
+	tests := map[string]string{
+		"/root":  "<h1>failed</h1>",
+		"/api/x": `{"error":"failed"}` + "\n",
+		"/web/x": "<h1>failed</h1>",
+	}
+	for path, expected := range tests {
+		w := httptest.NewRecorder()
+		srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusInternalServerError || w.Body.String() != expected {
+			t.Errorf("%s %s expected %d %s, returned %d %s", t.Name(), path, http.StatusInternalServerError, expected, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestWrap_DefaultErrorHandler(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GET("/x", srv.Wrap(func(w http.ResponseWriter, r *http.Request) error {
+		return fmt.Errorf("secret")
+	}))
+	srv.GET("/ok", srv.Wrap(func(w http.ResponseWriter, r *http.Request) error {
+		ResponseString(w, http.StatusOK, "ok")
+		return nil
+	}))
+
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/x", nil))
+	if w.Code != http.StatusInternalServerError || w.Body.String() != http.StatusText(http.StatusInternalServerError) {
+		t.Errorf("%s expected %d, returned %d %s", t.Name(), http.StatusInternalServerError, w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ok", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Errorf("%s expected %d ok, returned %d %s", t.Name(), http.StatusOK, w.Code, w.Body.String())
+	}
+}
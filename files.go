@@ -0,0 +1,63 @@
+package httpserver
+
+import (
+	"net/http"
+	"os"
+	"path"
+)
+
+// defaultIndex index file served by http.FileServer for directory request.
+const defaultIndex = "index.html"
+
+// FilesOpts options of serving files with FILESWith.
+type FilesOpts struct {
+	// Index name of file served for directory request, default to index.html.
+	Index string
+	// DisableListing respond 404 for directory without index file instead of listing its contents.
+	DisableListing bool
+}
+
+// filesFS http.FileSystem serving Index in place of index.html, and refusing to open directory without index file
+// if noListing, so http.FileServer responds 404 instead of listing it.
+type filesFS struct {
+	fs        http.FileSystem
+	index     string
+	noListing bool
+}
+
+func (ffs filesFS) Open(name string) (http.File, error) {
+	// http.FileServer always looks for index.html in directory.
+	if path.Base(name) == defaultIndex {
+		name = path.Join(path.Dir(name), ffs.index)
+	}
+	f, err := ffs.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if !ffs.noListing {
+		return f, nil
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if stat.IsDir() {
+		index, err := ffs.fs.Open(path.Join(name, ffs.index))
+		if err != nil {
+			f.Close()
+			return nil, os.ErrNotExist
+		}
+		index.Close()
+	}
+	return noListingFile{f}, nil
+}
+
+// noListingFile http.File failing to list its entries, in case directory is read anyway.
+type noListingFile struct {
+	http.File
+}
+
+func (noListingFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, os.ErrNotExist
+}
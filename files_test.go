@@ -0,0 +1,104 @@
+package httpserver
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFILESWith_DisableListing(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "sub", "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := New(&Opts{})
+	srv.FILES("/listed/*filepath", dir)
+	srv.FILESWith("/unlisted/*filepath", dir, &FilesOpts{DisableListing: true})
+
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/listed/sub/", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "secret.txt") {
+		t.Errorf("%s expected listing, returned %d %s", t.Name(), w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/unlisted/sub/", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusNotFound, w.Code)
+	}
+	if strings.Contains(w.Body.String(), "secret.txt") {
+		t.Errorf("%s expected no listing, returned %s", t.Name(), w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/unlisted/sub/secret.txt", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "secret" {
+		t.Errorf("%s expected file served, returned %d %s", t.Name(), w.Code, w.Body.String())
+	}
+}
+
+func TestFILESWith_Index(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"present", "absent"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "present", "home.html"), []byte("<h1>home</h1>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := New(&Opts{})
+	srv.FILESWith("/listed/*filepath", dir, &FilesOpts{Index: "home.html"})
+	srv.FILESWith("/unlisted/*filepath", dir, &FilesOpts{Index: "home.html", DisableListing: true})
+
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/unlisted/present/", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "<h1>home</h1>" {
+		t.Errorf("%s expected index served, returned %d %s", t.Name(), w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("%s expected text/html, returned %s", t.Name(), ct)
+	}
+
+	w = httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/unlisted/absent/", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusNotFound, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/listed/absent/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusOK, w.Code)
+	}
+}
+
+func TestFILE(t *testing.T) {
+	icon := filepath.Join(t.TempDir(), "favicon.ico")
+	content := []byte{0, 0, 1, 0, 1, 0}
+	if err := ioutil.WriteFile(icon, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := New(&Opts{})
+	srv.FILE("/favicon.ico", icon)
+	srv.Group("/static").FILE("/icon.ico", icon)
+
+	for _, path := range []string{"/favicon.ico", "/static/icon.ico"} {
+		w := httptest.NewRecorder()
+		srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), content) {
+			t.Errorf("%s %s expected %d %v, returned %d %v", t.Name(), path, http.StatusOK, content, w.Code, w.Body.Bytes())
+		}
+	}
+}
@@ -1,7 +1,7 @@
 package httpserver
 
 import (
-	"fmt"
+	"context"
 	"net/http"
 )
 
@@ -9,6 +9,10 @@ type Group struct {
 	server      *Server
 	prefix      string
 	middlewares []Middleware
+	// host of group created by HostGroup, inherited by its nested groups.
+	host string
+
+	errorHandler ErrorHandler
 }
 
 func (s *Server) Group(prefix string, middlewares ...Middleware) *Group {
@@ -19,37 +23,95 @@ func (s *Server) Group(prefix string, middlewares ...Middleware) *Group {
 	}
 }
 
-func (g *Group) GET(path string, handler http.HandlerFunc, middlewares ...Middleware) {
-	g.server.handlers.GET(fmt.Sprintf("%s%s", g.prefix, path), f(g.server.recoverPanic(g.chainMiddlewares(handler, middlewares...))))
+// Group create nested group under g, with prefix appended to g prefix and middlewares after g middlewares.
+// Error handler set on g is inherited.
+func (g *Group) Group(prefix string, middlewares ...Middleware) *Group {
+	return &Group{
+		server:       g.server,
+		prefix:       g.FullPath(prefix),
+		middlewares:  g.withMiddlewares(middlewares),
+		errorHandler: g.errorHandler,
+		host:         g.host,
+	}
+}
+
+// Prefix return full prefix of the group, including prefixes of its parent groups.
+func (g *Group) Prefix() string {
+	return g.prefix
+}
+
+// FullPath return path as registered in the server for path registered in the group,
+// e.g. to build links to the group routes.
+func (g *Group) FullPath(path string) string {
+	return g.prefix + path
+}
+
+func (g *Group) GET(path string, handler http.HandlerFunc, middlewares ...Middleware) *Route {
+	return g.register(http.MethodGet, path, handler, middlewares...)
+}
+
+func (g *Group) HEAD(path string, handler http.HandlerFunc, middlewares ...Middleware) *Route {
+	return g.register(http.MethodHead, path, handler, middlewares...)
 }
 
-func (g *Group) HEAD(path string, handler http.HandlerFunc, middlewares ...Middleware) {
-	g.server.handlers.HEAD(fmt.Sprintf("%s%s", g.prefix, path), f(g.server.recoverPanic(g.chainMiddlewares(handler, middlewares...))))
+func (g *Group) POST(path string, handler http.HandlerFunc, middlewares ...Middleware) *Route {
+	return g.register(http.MethodPost, path, handler, middlewares...)
 }
 
-func (g *Group) POST(path string, handler http.HandlerFunc, middlewares ...Middleware) {
-	g.server.handlers.POST(fmt.Sprintf("%s%s", g.prefix, path), f(g.server.recoverPanic(g.chainMiddlewares(handler, middlewares...))))
+func (g *Group) PUT(path string, handler http.HandlerFunc, middlewares ...Middleware) *Route {
+	return g.register(http.MethodPut, path, handler, middlewares...)
 }
 
-func (g *Group) PUT(path string, handler http.HandlerFunc, middlewares ...Middleware) {
-	g.server.handlers.PUT(fmt.Sprintf("%s%s", g.prefix, path), f(g.server.recoverPanic(g.chainMiddlewares(handler, middlewares...))))
+func (g *Group) DELETE(path string, handler http.HandlerFunc, middlewares ...Middleware) *Route {
+	return g.register(http.MethodDelete, path, handler, middlewares...)
 }
 
-func (g *Group) DELETE(path string, handler http.HandlerFunc, middlewares ...Middleware) {
-	g.server.handlers.DELETE(fmt.Sprintf("%s%s", g.prefix, path), f(g.server.recoverPanic(g.chainMiddlewares(handler, middlewares...))))
+func (g *Group) PATCH(path string, handler http.HandlerFunc, middlewares ...Middleware) *Route {
+	return g.register(http.MethodPatch, path, handler, middlewares...)
 }
 
-func (g *Group) PATCH(path string, handler http.HandlerFunc, middlewares ...Middleware) {
-	g.server.handlers.PATCH(fmt.Sprintf("%s%s", g.prefix, path), f(g.server.recoverPanic(g.chainMiddlewares(handler, middlewares...))))
+func (g *Group) OPTIONS(path string, handler http.HandlerFunc, middlewares ...Middleware) *Route {
+	return g.register(http.MethodOptions, path, handler, middlewares...)
 }
 
-func (g *Group) OPTIONS(path string, handler http.HandlerFunc, middlewares ...Middleware) {
-	g.server.handlers.OPTIONS(fmt.Sprintf("%s%s", g.prefix, path), f(g.server.recoverPanic(g.chainMiddlewares(handler, middlewares...))))
+// Method register handler for any method in a group path, see Server.Method.
+func (g *Group) Method(method string, path string, handler http.HandlerFunc, middlewares ...Middleware) *Route {
+	return g.register(method, path, handler, middlewares...)
 }
 
 // FILES serve files from 1 directory dynamically in a group path.
 // @filePath: must end with '/*filepath' as placeholder for filename to be accessed.
 // @rootPath: root directory where @filepath locate.
-func (g *Group) FILES(filePath string, rootPath string, middlewares ...Middleware) {
-	g.server.FILES(fmt.Sprintf("%s%s", g.prefix, filePath), rootPath, middlewares...)
+func (g *Group) FILES(filePath string, rootPath string, middlewares ...Middleware) *Route {
+	return g.FILESWith(filePath, rootPath, nil, middlewares...)
+}
+
+// FILE serve single file at exact urlPath in a group path, see Server.FILE.
+func (g *Group) FILE(urlPath string, filePath string, middlewares ...Middleware) *Route {
+	return g.GET(urlPath, fileHandler(filePath), middlewares...)
+}
+
+// FILESWith serve files like FILES with options in a group path. nil opts is the same as FILES.
+func (g *Group) FILESWith(filePath string, rootPath string, opts *FilesOpts, middlewares ...Middleware) *Route {
+	return g.GET(filePath, filesHandler(filePath, rootPath, opts), middlewares...)
+}
+
+// register delegate to server register with group prefix and group middlewares prepended.
+func (g *Group) register(method string, path string, handler http.HandlerFunc, middlewares ...Middleware) *Route {
+	return g.server.registerHost(g.host, method, g.FullPath(path), g.withPrefix(handler), g.withMiddlewares(middlewares)...)
+}
+
+// withPrefix put the group prefix into request context, read by GroupPrefix.
+func (g *Group) withPrefix(handler http.HandlerFunc) http.HandlerFunc {
+	prefix := g.prefix
+	return func(w http.ResponseWriter, r *http.Request) {
+		handler(w, r.WithContext(context.WithValue(r.Context(), groupKey, prefix)))
+	}
+}
+
+// withMiddlewares return group middlewares followed by route middlewares.
+func (g *Group) withMiddlewares(middlewares []Middleware) []Middleware {
+	m := make([]Middleware, 0, len(g.middlewares)+len(middlewares))
+	m = append(m, g.middlewares...)
+	return append(m, middlewares...)
 }
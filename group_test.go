@@ -2,6 +2,8 @@ package httpserver
 
 import (
 	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"testing"
 )
 
@@ -39,6 +41,99 @@ func TestGroupOPTIONS(t *testing.T) {
 	group.OPTIONS("/options", testHandler, TestMiddleware)
 }
 
+func TestGroupMethod(t *testing.T) {
+	group.Method("PROPFIND", "/propfind", testHandler, TestMiddleware)
+}
+
 func TestGroupFILES(t *testing.T) {
 	group.FILES("/test/*filepath", "/test/")
 }
+
+func TestGroupSameBehaviorAsServer(t *testing.T) {
+	tag := func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Tag", "tagged")
+			next(w, r)
+		}
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("panic") != "" {
+			panic("test panic")
+		}
+		ResponseString(w, http.StatusOK, r.URL.Query().Get("id"))
+	}
+	srv := New(&Opts{})
+	srv.GET("/items/:id", handler, tag)
+	srv.Group("/v1").GET("/items/:id", handler, tag)
+
+	for _, q := range []string{"", "?panic=1"} {
+		wSrv := httptest.NewRecorder()
+		srv.handlers.ServeHTTP(wSrv, httptest.NewRequest(http.MethodGet, "/items/7"+q, nil))
+		wGrp := httptest.NewRecorder()
+		srv.handlers.ServeHTTP(wGrp, httptest.NewRequest(http.MethodGet, "/v1/items/7"+q, nil))
+
+		if wSrv.Code != wGrp.Code || wSrv.Body.String() != wGrp.Body.String() {
+			t.Errorf("%s expected same response, server returned %d %s, group returned %d %s", t.Name(), wSrv.Code, wSrv.Body.String(), wGrp.Code, wGrp.Body.String())
+		}
+		if wSrv.Header().Get("X-Tag") != "tagged" || wGrp.Header().Get("X-Tag") != "tagged" {
+			t.Errorf("%s expected middleware applied on both", t.Name())
+		}
+		if wSrv.Header().Get("Request-Id") == "" || wGrp.Header().Get("Request-Id") == "" {
+			t.Errorf("%s expected Header Request-Id not empty on both", t.Name())
+		}
+	}
+
+	expected := []Route{
+		{Method: http.MethodGet, Path: "/items/:id"},
+		{Method: http.MethodGet, Path: "/v1/items/:id"},
+	}
+	if routes := srv.Routes(); !reflect.DeepEqual(routes, expected) {
+		t.Errorf("%s expected %v, returned %v", t.Name(), expected, routes)
+	}
+}
+
+func TestGroupPrefix(t *testing.T) {
+	var called []string
+	tag := func(name string) Middleware {
+		return func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				called = append(called, name)
+				next(w, r)
+			}
+		}
+	}
+	srv := New(&Opts{})
+	v1 := srv.Group("/v1", tag("v1"))
+	if v1.Prefix() != "/v1" || v1.FullPath("/users") != "/v1/users" {
+		t.Errorf("%s expected %s %s, returned %s %s", t.Name(), "/v1", "/v1/users", v1.Prefix(), v1.FullPath("/users"))
+	}
+	admin := v1.Group("/admin", tag("admin"))
+	if admin.Prefix() != "/v1/admin" || admin.FullPath("/users") != "/v1/admin/users" {
+		t.Errorf("%s expected %s %s, returned %s %s", t.Name(), "/v1/admin", "/v1/admin/users", admin.Prefix(), admin.FullPath("/users"))
+	}
+
+	route := admin.GET("/users", testHandler)
+	if route.Path != admin.FullPath("/users") {
+		t.Errorf("%s expected %s, returned %s", t.Name(), admin.FullPath("/users"), route.Path)
+	}
+	srv.handlers.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/admin/users", nil))
+	if !reflect.DeepEqual(called, []string{"v1", "admin"}) {
+		t.Errorf("%s expected %v, returned %v", t.Name(), []string{"v1", "admin"}, called)
+	}
+}
+
+func TestGroupFILES_GroupMiddlewares(t *testing.T) {
+	called := false
+	m := func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			next(w, r)
+		}
+	}
+	srv := New(&Opts{})
+	srv.Group("/static", m).FILES("/*filepath", ".")
+	srv.handlers.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/static/go.mod", nil))
+	if !called {
+		t.Errorf("%s expected group middleware called", t.Name())
+	}
+}
@@ -0,0 +1,53 @@
+package httpserver
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// headHandler serve HEAD request of path having GET route only, by running the GET handler with body discarded.
+func (s *Server) headHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			router := s.router(r)
+			if handle, _, _ := router.Lookup(http.MethodHead, r.URL.Path); handle == nil {
+				if handle, ps, _ := router.Lookup(http.MethodGet, r.URL.Path); handle != nil {
+					hw := &headWriter{ResponseWriter: w, statusCode: http.StatusOK}
+					handle(hw, r, ps)
+					hw.flush()
+					return
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// headWriter discard body while counting it, delaying header until handler returns so Content-Length can be set
+// to the length of the body GET would have responded.
+type headWriter struct {
+	http.ResponseWriter
+	statusCode int
+	length     int
+}
+
+func (hw *headWriter) WriteHeader(statusCode int) {
+	hw.statusCode = statusCode
+}
+
+func (hw *headWriter) Write(p []byte) (int, error) {
+	hw.length += len(p)
+	return len(p), nil
+}
+
+// Unwrap return the wrapped http.ResponseWriter.
+func (hw *headWriter) Unwrap() http.ResponseWriter {
+	return hw.ResponseWriter
+}
+
+func (hw *headWriter) flush() {
+	if hw.Header().Get("Content-Length") == "" && hw.length > 0 {
+		hw.Header().Set("Content-Length", strconv.Itoa(hw.length))
+	}
+	hw.ResponseWriter.WriteHeader(hw.statusCode)
+}
@@ -0,0 +1,46 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHeadForGet(t *testing.T) {
+	srv := New(&Opts{HeadForGet: true})
+	srv.GET("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Page", "page")
+		ResponseString(w, http.StatusOK, "page body")
+	})
+	srv.GET("/explicit", testHandler)
+	srv.HEAD("/explicit", func(w http.ResponseWriter, r *http.Request) {
+		ResponseStatus(w, http.StatusNoContent)
+	})
+	h := srv.Handler()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/page", nil))
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("%s expected %d without body, returned %d %s", t.Name(), http.StatusOK, w.Code, w.Body.String())
+	}
+	if w.Header().Get("X-Page") != "page" || w.Header().Get("Request-Id") == "" {
+		t.Errorf("%s expected headers of GET handler, returned %v", t.Name(), w.Header())
+	}
+	if cl := w.Header().Get("Content-Length"); cl != "9" {
+		t.Errorf("%s expected Content-Length %s, returned %s", t.Name(), "9", cl)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/explicit", nil))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusNoContent, w.Code)
+	}
+
+	srv = New(&Opts{})
+	srv.GET("/page", testHandler)
+	w = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/page", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("%s expected %d without HeadForGet, returned %d", t.Name(), http.StatusMethodNotAllowed, w.Code)
+	}
+}
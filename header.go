@@ -0,0 +1,48 @@
+package httpserver
+
+import (
+	"net/http"
+	"strings"
+)
+
+// defaultHeadersHandler set s.defaultHeaders into response header before passing request into next.
+func (s *Server) defaultHeadersHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for k, v := range s.defaultHeaders {
+			w.Header().Set(k, v)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// copyHeaders return copy of headers, so caller modifying its map later does not race with requests reading it.
+func copyHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	c := make(map[string]string, len(headers))
+	for k, v := range headers {
+		c[k] = v
+	}
+	return c
+}
+
+// RequireHeaders middleware to respond 400 Bad Request if any of names header is missing or blank, e.g. API key or tenant id,
+// with json body naming the first missing one, `{"error":"missing required header","header":"X-Api-Key"}`.
+// Put it early in the chain, e.g. via Use or first of route middlewares, so nothing runs for rejected request.
+func RequireHeaders(names ...string) Middleware {
+	return func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			for _, name := range names {
+				if strings.TrimSpace(r.Header.Get(name)) == "" {
+					ResponseJSON(w, http.StatusBadRequest, map[string]string{
+						"error":  "missing required header",
+						"header": name,
+					})
+					return
+				}
+			}
+			next(w, r)
+		}
+	}
+}
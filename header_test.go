@@ -0,0 +1,58 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireHeaders(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GET("/tenant", okHandler, RequireHeaders("X-Api-Key", "X-Tenant-Id"))
+
+	r := httptest.NewRequest(http.MethodGet, "/tenant", nil)
+	r.Header.Set("X-Api-Key", "secret")
+	r.Header.Set("X-Tenant-Id", " ")
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, r)
+	expected := `{"error":"missing required header","header":"X-Tenant-Id"}` + "\n"
+	if w.Code != http.StatusBadRequest || w.Body.String() != expected {
+		t.Errorf("%s expected %d %s, returned %d %s", t.Name(), http.StatusBadRequest, expected, w.Code, w.Body.String())
+	}
+
+	r.Header.Set("X-Tenant-Id", "acme")
+	w = httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Errorf("%s expected %d ok, returned %d %s", t.Name(), http.StatusOK, w.Code, w.Body.String())
+	}
+}
+
+func TestDefaultHeaders(t *testing.T) {
+	headers := map[string]string{"Server": "api", "Vary": "Origin"}
+	srv := New(&Opts{DefaultHeaders: headers})
+	headers["Server"] = "changed" // copied by New, not seen by requests.
+	srv.GET("/default", okHandler)
+	srv.GET("/override", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "custom")
+		w.Header().Del("Vary")
+		ResponseString(w, http.StatusOK, "ok")
+	})
+
+	tests := []struct {
+		path   string
+		server string
+		vary   string
+	}{
+		{"/default", "api", "Origin"},
+		{"/override", "custom", ""},
+		{"/notfound", "api", "Origin"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Header().Get("Server") != tt.server || w.Header().Get("Vary") != tt.vary {
+			t.Errorf("%s %s expected Server %q Vary %q, returned %q %q", t.Name(), tt.path, tt.server, tt.vary, w.Header().Get("Server"), w.Header().Get("Vary"))
+		}
+	}
+}
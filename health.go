@@ -0,0 +1,50 @@
+package httpserver
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// ReadyPath path of built-in readiness probe, responding 200 OK if server is ready, 503 Service Unavailable otherwise.
+// Route registered on the same path takes precedence.
+// Probe is answered ahead of StripPrefix, request id and access log, so ReadyPath is not prefixed and the probe has
+// no Request-Id nor log entry, it is counted in Stats though.
+const ReadyPath = "/readyz"
+
+// SetReady set whether server is ready to receive traffic, reported by ReadyPath.
+// Server is ready by default, Shutdown set it to not ready before draining connections.
+func (s *Server) SetReady(ready bool) {
+	var notReady int32
+	if !ready {
+		notReady = 1
+	}
+	atomic.StoreInt32(&s.notReady, notReady)
+}
+
+// Ready return whether server is ready to receive traffic.
+func (s *Server) Ready() bool {
+	return atomic.LoadInt32(&s.notReady) == 0
+}
+
+// readyHandler respond ReadyPath request with readiness of server, before passing other requests into next.
+func (s *Server) readyHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != ReadyPath || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if h, _, _ := s.router(r).Lookup(r.Method, r.URL.Path); h != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		status := http.StatusOK
+		if !s.Ready() {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		if r.Method == http.MethodGet {
+			w.Write([]byte(http.StatusText(status)))
+		}
+	})
+}
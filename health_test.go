@@ -0,0 +1,104 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReady(t *testing.T) {
+	srv := New(&Opts{})
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, ReadyPath, nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusOK, w.Code)
+	}
+
+	srv.SetReady(false)
+	w = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, ReadyPath, nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusServiceUnavailable, w.Code)
+	}
+
+	srv.GET(ReadyPath, okHandler)
+	w = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, ReadyPath, nil))
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Errorf("%s expected registered route, returned %d %s", t.Name(), w.Code, w.Body.String())
+	}
+}
+
+func TestShutdown_NotReadyBeforeDrain(t *testing.T) {
+	srv := New(&Opts{PreStopDelay: 200 * time.Millisecond})
+	srv.GET("/ok", okHandler)
+	if err := srv.Start(); err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	addr := srv.listener.Addr()
+
+	done := make(chan error, 1)
+	go func() { done <- srv.Shutdown(context.Background()) }()
+	for srv.Ready() {
+		time.Sleep(time.Millisecond)
+	}
+
+	resp, err := http.Get(fmt.Sprintf("http://%s%s", addr, ReadyPath))
+	if err != nil {
+		t.Fatalf("%s expected server still accepting, found %v", t.Name(), err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusServiceUnavailable, resp.StatusCode)
+	}
+
+	if err := <-done; err != nil {
+		t.Errorf("%s expected null error, found %v", t.Name(), err)
+	}
+	if _, err := http.Get(fmt.Sprintf("http://%s/ok", addr)); err == nil {
+		t.Errorf("%s expected connection refused after shutdown", t.Name())
+	}
+}
+
+func TestShutdownWithTimeout_PreStopDelay(t *testing.T) {
+	srv := New(&Opts{PreStopDelay: 100 * time.Millisecond})
+	entered := make(chan struct{})
+	srv.GET("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		time.Sleep(150 * time.Millisecond)
+		ResponseString(w, http.StatusOK, "ok")
+	})
+	if err := srv.Start(); err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	go http.Get(fmt.Sprintf("http://%s/slow", srv.listener.Addr()))
+	<-entered
+
+	// grace covers draining the slow request only if it is not spent by PreStopDelay.
+	forced, err := srv.ShutdownWithTimeout(100 * time.Millisecond)
+	if forced || err != nil {
+		t.Errorf("%s expected graceful shutdown, returned %v %v", t.Name(), forced, err)
+	}
+}
+
+func TestShutdown_PreStopDelayExceedsDeadline(t *testing.T) {
+	var buf bytes.Buffer
+	srv := New(&Opts{PreStopDelay: time.Second})
+	srv.logger = log.New(&buf, "", 0)
+	if err := srv.Start(); err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	srv.Shutdown(ctx)
+	if !strings.Contains(buf.String(), "no time left to drain connections") {
+		t.Errorf("%s expected exhausted deadline logged, returned %s", t.Name(), buf.String())
+	}
+	srv.server.Close()
+}
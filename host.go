@@ -0,0 +1,78 @@
+package httpserver
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	_router "github.com/julienschmidt/httprouter"
+)
+
+// HostGroup create group of routes matched only for requests whose Host header is host, e.g. api.example.com,
+// so one server can serve several virtual hosts, each having own routes on the same paths.
+// Port in Host header is ignored and matching is case-insensitive. Requests of other hosts are routed to
+// the routes registered directly on the server.
+// Router is path based, so each host has own router and the request is dispatched into it by host before routing.
+// Server configuration and middlewares apply to all hosts. Lookup and Middlewares only see routes of the server itself.
+func (s *Server) HostGroup(host string, middlewares ...Middleware) *Group {
+	return &Group{
+		server:      s,
+		host:        normalizeHost(host),
+		middlewares: middlewares,
+	}
+}
+
+// HostGroup create nested group under g matched only for requests whose Host header is host, see Server.HostGroup.
+// Prefix, middlewares and error handler of g are inherited.
+func (g *Group) HostGroup(host string, middlewares ...Middleware) *Group {
+	hg := g.Group("", middlewares...)
+	hg.host = normalizeHost(host)
+	return hg
+}
+
+// normalizeHost lowercase host and strip its port if any.
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+// hostRouter return router of host, created on first use. Router of the server itself if host is empty.
+func (s *Server) hostRouter(host string) *_router.Router {
+	if host == "" {
+		return s.handlers
+	}
+	if s.hostRouters == nil {
+		s.hostRouters = make(map[string]*_router.Router)
+	}
+	h, ok := s.hostRouters[host]
+	if !ok {
+		h = copyRouter(s.handlers)
+		s.hostRouters[host] = h
+	}
+	return h
+}
+
+// router return router serving r, by its Host header, from routers stored by Handler or UpdateRoutes.
+// Once stored, the server routers are never read here, as UpdateRoutes replaces them while serving.
+// Falls back to the server routers if Handler is not called yet.
+func (s *Server) router(r *http.Request) *_router.Router {
+	rt, ok := s.routing.Load().(*routing)
+	if !ok {
+		rt = &routing{handlers: s.handlers, hostRouters: s.hostRouters}
+	}
+	if len(rt.hostRouters) > 0 {
+		if h, ok := rt.hostRouters[normalizeHost(r.Host)]; ok {
+			return h
+		}
+	}
+	return rt.handlers
+}
+
+// routerHandler dispatch request into router of its host.
+func (s *Server) routerHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.router(r).ServeHTTP(w, r)
+	})
+}
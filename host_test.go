@@ -0,0 +1,45 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHostGroup(t *testing.T) {
+	srv := New(&Opts{})
+	srv.HostGroup("api.example.com").GET("/home", func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusOK, "api")
+	})
+	srv.HostGroup("app.example.com").GET("/home", func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusOK, "app")
+	})
+	srv.GET("/other", okHandler)
+
+	tests := []struct {
+		host, path string
+		code       int
+		body       string
+	}{
+		{"api.example.com", "/home", http.StatusOK, "api"},
+		{"APP.example.com:8080", "/home", http.StatusOK, "app"},
+		{"other.example.com", "/home", http.StatusNotFound, ""},
+		{"other.example.com", "/other", http.StatusOK, "ok"},
+		{"api.example.com", "/other", http.StatusNotFound, ""},
+	}
+	h := srv.Handler()
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		r.Host = tt.host
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.code || (tt.body != "" && w.Body.String() != tt.body) {
+			t.Errorf("%s %s%s expected %d %s, returned %d %s", t.Name(), tt.host, tt.path, tt.code, tt.body, w.Code, w.Body.String())
+		}
+	}
+
+	expected := Route{Method: http.MethodGet, Path: "/home", Host: "api.example.com"}
+	if routes := srv.Routes(); routes[0].Host != expected.Host || routes[0].Path != expected.Path {
+		t.Errorf("%s expected %v, returned %v", t.Name(), expected, routes[0])
+	}
+}
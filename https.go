@@ -0,0 +1,203 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPSMode how RequireHTTPS treats plaintext request.
+type HTTPSMode int
+
+const (
+	// Redirect plaintext request to its https url with 308 Permanent Redirect.
+	Redirect HTTPSMode = iota
+	// Reject plaintext request with 403 Forbidden.
+	Reject
+)
+
+// HTTPSOpts options of RequireHTTPSWith.
+type HTTPSOpts struct {
+	// Mode how plaintext request is treated.
+	Mode HTTPSMode
+	// TrustForwardedProto consider request https if X-Forwarded-Proto header is https.
+	// Only enable it behind tls terminating proxy, otherwise client can set the header itself.
+	TrustForwardedProto bool
+	// TrustedProxies ip addresses of proxies whose X-Forwarded-Proto is trusted, required with TrustForwardedProto.
+	// Empty means no proxy is trusted.
+	TrustedProxies []string
+	// Host optional, canonical host redirected to, e.g. example.com. If empty then Host header of the request is used.
+	Host string
+}
+
+// RequireHTTPS middleware to enforce https, either by redirecting or rejecting plaintext request depending on mode.
+// Request is considered https only if it came over tls, X-Forwarded-Proto is not trusted since any client can set it.
+// Behind tls terminating proxy use RequireHTTPSWith to trust X-Forwarded-Proto from the proxy.
+func RequireHTTPS(mode HTTPSMode) Middleware {
+	return RequireHTTPSWith(HTTPSOpts{Mode: mode})
+}
+
+// RequireHTTPSWith middleware to enforce https like RequireHTTPS with configurable proxy trust.
+func RequireHTTPSWith(opts HTTPSOpts) Middleware {
+	return func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if isHTTPS(r, opts) {
+				next(w, r)
+				return
+			}
+			if opts.Mode == Reject {
+				ResponseString(w, http.StatusForbidden, http.StatusText(http.StatusForbidden))
+				return
+			}
+			host := opts.Host
+			if host == "" {
+				host = r.Host
+			}
+			http.Redirect(w, r, "https://"+host+originalRequestURI(r), http.StatusPermanentRedirect)
+		}
+	}
+}
+
+// originalRequestURI return path and query as sent by client, without route params added into r.URL by the router
+// nor prefix stripped by StripPrefix.
+func originalRequestURI(r *http.Request) string {
+	if strings.HasPrefix(r.RequestURI, "/") {
+		return r.RequestURI
+	}
+	if u, err := url.ParseRequestURI(r.RequestURI); err == nil { // absolute form.
+		return u.RequestURI()
+	}
+	return r.URL.RequestURI()
+}
+
+func isHTTPS(r *http.Request, opts HTTPSOpts) bool {
+	if r.TLS != nil {
+		return true
+	}
+	if !opts.TrustForwardedProto || !isTrustedProxy(r.RemoteAddr, opts.TrustedProxies) {
+		return false
+	}
+	return strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+func isTrustedProxy(remoteAddr string, proxies []string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	for _, proxy := range proxies {
+		if proxy == host {
+			return true
+		}
+	}
+	return false
+}
+
+// RunTLSWithRedirect serve tls on httpsPort and redirect plaintext request on httpPort into its https url
+// with 308 Permanent Redirect. Blocking.
+// TLS config must be set via Opts.TLS or TLSConfig. Returns once either listener stopped, after stopping the other.
+// Graceful restart is not supported in this mode.
+func (s *Server) RunTLSWithRedirect(httpPort, httpsPort uint16) error {
+	wait, err := s.startTLSWithRedirect(httpPort, httpsPort)
+	if err != nil {
+		return err
+	}
+	return wait()
+}
+
+// StartTLSWithRedirect serve like RunTLSWithRedirect in background, binding both listeners synchronously like Start,
+// so Shutdown right after it returns stops the servers. Non-blocking.
+// Error happened after the server started is sent into ListenError channel.
+func (s *Server) StartTLSWithRedirect(httpPort, httpsPort uint16) error {
+	wait, err := s.startTLSWithRedirect(httpPort, httpsPort)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := wait(); err != nil {
+			s.errChan <- err
+		}
+	}()
+	return nil
+}
+
+// startTLSWithRedirect bind both ports and serve them in background, wait return once either listener stopped.
+func (s *Server) startTLSWithRedirect(httpPort, httpsPort uint16) (wait func() error, err error) {
+	if s.tls == nil {
+		return nil, errors.New("httpserver: tls config is not set")
+	}
+	if err := s.validateTLS(); err != nil {
+		s.logger.Printf("%s | httpserver | server failed with error: %v", time.Now().Format(time.RFC3339), err)
+		return nil, err
+	}
+	httpsLn, err := s.listenTCP(httpsPort)
+	if err != nil {
+		s.logger.Printf("%s | httpserver | server failed to bind with error: %v", time.Now().Format(time.RFC3339), err)
+		return nil, err
+	}
+	httpLn, err := s.listenTCP(httpPort)
+	if err != nil {
+		httpsLn.Close()
+		s.logger.Printf("%s | httpserver | server failed to bind with error: %v", time.Now().Format(time.RFC3339), err)
+		return nil, err
+	}
+	return s.serveTLSWithRedirect(httpsLn, httpLn), nil
+}
+
+// serveTLSWithRedirect serve tls on httpsLn and redirect plaintext request on httpLn into the port of httpsLn
+// in background. The server fields are set before it returns, wait return once either listener stopped,
+// after stopping the other.
+func (s *Server) serveTLSWithRedirect(httpsLn, httpLn net.Listener) (wait func() error) {
+	var httpsPort uint16
+	if addr, ok := httpsLn.Addr().(*net.TCPAddr); ok {
+		httpsPort = uint16(addr.Port)
+	}
+	srv := s.httpServer()
+	redirect := &http.Server{
+		Handler:     redirectHTTPS(httpsPort),
+		IdleTimeout: s.idleTimeout,
+	}
+	s.serverMu.Lock()
+	s.listener = httpsLn
+	s.server = srv
+	s.redirectServer = redirect
+	s.serverMu.Unlock()
+	s.logger.Printf("%s | httpserver | server is running on %s, redirecting from %s", time.Now().Format(time.RFC3339), httpsLn.Addr(), httpLn.Addr())
+
+	errs := make(chan error, 2)
+	go func() { errs <- srv.ServeTLS(httpsLn, "", "") }()
+	go func() { errs <- redirect.Serve(httpLn) }()
+	return func() error {
+		err := <-errs
+		srv.Shutdown(context.Background())
+		redirect.Shutdown(context.Background())
+		<-errs
+		if err == http.ErrServerClosed {
+			return nil
+		}
+		s.logger.Printf("%s | httpserver | server failed with error: %v", time.Now().Format(time.RFC3339), err)
+		return err
+	}
+}
+
+// redirectHTTPS redirect request into the same url on https at httpsPort, omitted if it is the default 443.
+func redirectHTTPS(httpsPort uint16) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(int(httpsPort)))
+		}
+		u := *r.URL
+		u.Scheme = "https"
+		u.Host = host
+		http.Redirect(w, r, u.String(), http.StatusPermanentRedirect)
+	}
+}
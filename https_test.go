@@ -0,0 +1,276 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func okHandler(w http.ResponseWriter, r *http.Request) {
+	ResponseString(w, http.StatusOK, "ok")
+}
+
+func TestRequireHTTPS_Redirect(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GET("/secure", okHandler, RequireHTTPS(Redirect))
+
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://example.com/secure?a=1", nil))
+	if w.Code != http.StatusPermanentRedirect {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusPermanentRedirect, w.Code)
+	}
+	if loc := w.Header().Get("Location"); loc != "https://example.com/secure?a=1" {
+		t.Errorf("%s expected %s, returned %s", t.Name(), "https://example.com/secure?a=1", loc)
+	}
+}
+
+func TestRequireHTTPS_Reject(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GET("/secure", okHandler, RequireHTTPS(Reject))
+
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/secure", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusForbidden, w.Code)
+	}
+}
+
+func TestRequireHTTPS_Pass(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GET("/secure", okHandler, RequireHTTPS(Reject))
+
+	r := httptest.NewRequest(http.MethodGet, "/secure", nil)
+	r.TLS = &tls.ConnectionState{}
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusOK, w.Code)
+	}
+
+	// spoofed by client.
+	r = httptest.NewRequest(http.MethodGet, "/secure", nil)
+	r.Header.Set("X-Forwarded-Proto", "https")
+	w = httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusForbidden, w.Code)
+	}
+}
+
+func TestRequireHTTPSWith_RedirectURL(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GET("/users/:id", okHandler, RequireHTTPS(Redirect))
+	srv.GET("/canonical/:id", okHandler, RequireHTTPSWith(HTTPSOpts{Host: "example.com"}))
+
+	tests := map[string]string{
+		"http://evil.com/users/7?a=1":     "https://evil.com/users/7?a=1",
+		"http://evil.com/canonical/7?a=1": "https://example.com/canonical/7?a=1",
+	}
+	for target, expected := range tests {
+		w := httptest.NewRecorder()
+		srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if loc := w.Header().Get("Location"); loc != expected {
+			t.Errorf("%s expected %s, returned %s", t.Name(), expected, loc)
+		}
+	}
+}
+
+func TestRequireHTTPSWith_Direct(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GET("/secure", okHandler, RequireHTTPSWith(HTTPSOpts{Mode: Reject}))
+
+	r := httptest.NewRequest(http.MethodGet, "/secure", nil)
+	r.Header.Set("X-Forwarded-Proto", "https")
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusForbidden, w.Code)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/secure", nil)
+	r.TLS = &tls.ConnectionState{}
+	w = httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusOK, w.Code)
+	}
+}
+
+func TestRequireHTTPSWith_Proxied(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GET("/secure", okHandler, RequireHTTPSWith(HTTPSOpts{
+		Mode:                Reject,
+		TrustForwardedProto: true,
+		TrustedProxies:      []string{"10.0.0.1"},
+	}))
+
+	tests := []struct {
+		remoteAddr string
+		proto      string
+		expected   int
+	}{
+		{"10.0.0.1:4321", "https", http.StatusOK},
+		{"10.0.0.1:4321", "http", http.StatusForbidden},
+		{"10.0.0.2:4321", "https", http.StatusForbidden},
+	}
+	srv.GET("/untrusted", okHandler, RequireHTTPSWith(HTTPSOpts{Mode: Reject, TrustForwardedProto: true}))
+	r := httptest.NewRequest(http.MethodGet, "/untrusted", nil)
+	r.RemoteAddr = "10.0.0.1:4321"
+	r.Header.Set("X-Forwarded-Proto", "https")
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("%s expected %d without trusted proxies, returned %d", t.Name(), http.StatusForbidden, w.Code)
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/secure", nil)
+		r.RemoteAddr = test.remoteAddr
+		r.Header.Set("X-Forwarded-Proto", test.proto)
+		w := httptest.NewRecorder()
+		srv.handlers.ServeHTTP(w, r)
+		if w.Code != test.expected {
+			t.Errorf("%s expected %d for %s %s, returned %d", t.Name(), test.expected, test.remoteAddr, test.proto, w.Code)
+		}
+	}
+}
+
+func TestTLSConfig_MissingCertificate(t *testing.T) {
+	srv := New(&Opts{})
+	err := srv.TLSConfig("nonexistent.crt", "nonexistent.key")
+	if err == nil || !strings.Contains(err.Error(), "nonexistent.crt") {
+		t.Errorf("%s expected error naming the certificate, returned %v", t.Name(), err)
+	}
+
+	srv = New(&Opts{TLS: &tls.Config{}})
+	if err := srv.Start(); err != ErrNoCertificate {
+		if err == nil {
+			srv.server.Close()
+		}
+		t.Errorf("%s expected %v, returned %v", t.Name(), ErrNoCertificate, err)
+	}
+}
+
+func TestRun_EmptyTLSConfig(t *testing.T) {
+	srv := New(&Opts{TLS: &tls.Config{}})
+	srv.logger = log.New(ioutil.Discard, "", 0)
+	go srv.Run()
+	select {
+	case err := <-srv.ListenError():
+		if err != ErrNoCertificate || !strings.Contains(err.Error(), "GetCertificate") {
+			t.Errorf("%s expected %v, returned %v", t.Name(), ErrNoCertificate, err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("%s expected startup error, returned none", t.Name())
+	}
+}
+
+func TestNextProtos(t *testing.T) {
+	tlsConfig := &tls.Config{}
+	srv := New(&Opts{TLS: tlsConfig, NextProtos: []string{"http/1.1"}})
+	hs := srv.httpServer()
+	if !reflect.DeepEqual(hs.TLSConfig.NextProtos, []string{"http/1.1"}) || hs.TLSNextProto == nil {
+		t.Errorf("%s expected http/1.1 only, returned %v %v", t.Name(), hs.TLSConfig.NextProtos, hs.TLSNextProto)
+	}
+	if len(tlsConfig.NextProtos) != 0 {
+		t.Errorf("%s expected configured tls config untouched, returned %v", t.Name(), tlsConfig.NextProtos)
+	}
+
+	srv = New(&Opts{TLS: tlsConfig, NextProtos: []string{"h2", "http/1.1"}})
+	if hs := srv.httpServer(); hs.TLSNextProto != nil {
+		t.Errorf("%s expected http/2 kept, returned %v", t.Name(), hs.TLSNextProto)
+	}
+	if hs := New(&Opts{TLS: tlsConfig}).httpServer(); hs.TLSConfig != tlsConfig || hs.TLSNextProto != nil {
+		t.Errorf("%s expected default negotiation", t.Name())
+	}
+}
+
+func TestRunTLSWithRedirect(t *testing.T) {
+	// borrow self-signed certificate of httptest and its client trusting it.
+	ts := httptest.NewTLSServer(http.NotFoundHandler())
+	client := ts.Client()
+	tlsConfig := ts.TLS.Clone()
+	ts.Close()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	srv := New(&Opts{TLS: tlsConfig})
+	srv.GET("/secure", okHandler)
+	httpsLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	wait := srv.serveTLSWithRedirect(httpsLn, httpLn)
+	go func() { done <- wait() }()
+
+	resp, err := client.Get("http://" + httpLn.Addr().String() + "/secure?q=1")
+	if err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	resp.Body.Close()
+	if expected := "https://" + httpsLn.Addr().String() + "/secure?q=1"; resp.StatusCode != http.StatusPermanentRedirect || resp.Header.Get("Location") != expected {
+		t.Errorf("%s expected %d to %s, returned %d %s", t.Name(), http.StatusPermanentRedirect, expected, resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	resp, err = client.Get("https://" + httpsLn.Addr().String() + "/secure")
+	if err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "ok" {
+		t.Errorf("%s expected %d ok, returned %d %s", t.Name(), http.StatusOK, resp.StatusCode, body)
+	}
+
+	srv.server.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("%s expected null error, found %v", t.Name(), err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("%s expected both listeners stopped", t.Name())
+	}
+}
+
+func TestStartTLSWithRedirect_Shutdown(t *testing.T) {
+	ts := httptest.NewTLSServer(http.NotFoundHandler())
+	tlsConfig := ts.TLS.Clone()
+	ts.Close()
+
+	srv := New(&Opts{TLS: tlsConfig})
+	srv.GET("/secure", okHandler)
+	if err := srv.StartTLSWithRedirect(0, 0); err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	ln, err := srv.Listener()
+	if err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Errorf("%s expected null error, found %v", t.Name(), err)
+	}
+	if conn, err := net.Dial("tcp", ln.Addr().String()); err == nil {
+		conn.Close()
+		t.Errorf("%s expected listener closed after Shutdown", t.Name())
+	}
+	select {
+	case err := <-srv.ListenError():
+		t.Errorf("%s expected no listen error, returned %v", t.Name(), err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
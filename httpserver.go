@@ -1,11 +1,23 @@
 package httpserver
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	_uuid "github.com/google/uuid"
@@ -13,20 +25,75 @@ import (
 	_cors "github.com/rs/cors"
 )
 
+// ErrNotStarted returned if server is not started via Start yet.
+var ErrNotStarted = errors.New("httpserver: server is not started")
+
+// ErrNoCertificate returned on start if TLS config is set without any certificate, nor GetCertificate
+// or GetConfigForClient to provide one.
+var ErrNoCertificate = errors.New("httpserver: tls config has no certificate, set Certificates or GetCertificate, e.g. via TLSConfig")
+
 type Server struct {
-	handlers    *_router.Router
+	// stats must be the first field to keep 64-bit atomic operations aligned on 32-bit platforms.
+	stats stats
+
+	handlers *_router.Router
+	// routing routers served by Handler, swapped atomically by UpdateRoutes. See router.
+	routing atomic.Value
+	// routingMu serialize UpdateRoutes.
+	routingMu   sync.Mutex
 	errChan     chan error
 	port        uint16
 	idleTimeout time.Duration
 	logger      *log.Logger
-	tls         *tls.Config
-	cors        *_cors.Cors
+	// accessLogger optional, logger of access log if it goes to other destination than logger.
+	accessLogger *log.Logger
+	tls          *tls.Config
+	cors         *_cors.Cors
+	// methodCors optional, cors of specific methods overriding cors.
+	methodCors  map[string]*_cors.Cors
 	middlewares []Middleware
 
-	panicHandler    PanicHandler
-	notFoundHandler http.Handler
+	panicHandler            PanicHandler
+	debugPanics             bool
+	notFoundHandler         http.Handler
+	methodNotAllowedHandler http.Handler
+	errorHandler            ErrorHandler
+	connState               func(net.Conn, http.ConnState)
+	baseContext             func(net.Listener) context.Context
+	connContext             func(ctx context.Context, c net.Conn) context.Context
+	stripPrefix             string
+	maxURLLength            int
+	cleanPath               bool
+	redirectCleanPath       bool
+	inheritFD               int
+	disableRequestID        bool
+	maxMultipartMemory      int64
+	headForGet              bool
+	preStopDelay            time.Duration
+	keepAlivePeriod         time.Duration
+	listenControl           func(network, address string, c syscall.RawConn) error
+	nextProtos              []string
+	defaultHeaders          map[string]string
+	// notReady accessed atomically, zero means ready, see SetReady.
+	notReady         int32
+	routes           []*Route
+	routeMiddlewares map[string][]Middleware
+	// hostRouters optional, router of each host registered via HostGroup.
+	hostRouters map[string]*_router.Router
+
+	// serverMu guard listener, server and redirectServer, set on start while Shutdown may read them concurrently.
+	serverMu sync.Mutex
+	// listener and server are set once the server is started via Start.
+	listener net.Listener
+	server   *http.Server
+	// redirectServer plaintext server redirecting to https, set by RunTLSWithRedirect.
+	redirectServer *http.Server
 }
 
+// Middleware wrap next handler with additional behavior.
+// A Middleware value is called once per route registration to build that route's chain,
+// so the same value can safely be reused across many routes, groups and the server itself.
+// Keep any per-request state inside the returned http.HandlerFunc, not in the Middleware closure.
 type Middleware func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc
 type PanicHandler func(w http.ResponseWriter, r *http.Request, rcv ...interface{})
 
@@ -36,6 +103,14 @@ type Opts struct {
 	// EnableLogger enable logging for incoming requests
 	EnableLogger bool
 
+	// AccessLogWriter optional, destination of access log written if EnableLogger, e.g. os.Stdout.
+	// If empty then access log goes with the other logs.
+	AccessLogWriter io.Writer
+
+	// ErrorLogWriter optional, destination of startup, error and panic logs, e.g. os.Stderr.
+	// If empty then stderr is used, asynchronously if EnableLogger.
+	ErrorLogWriter io.Writer
+
 	// IdleTimeout keep-alive timeout while waiting for the next request coming. If empty then no timeout.
 	IdleTimeout time.Duration
 
@@ -47,11 +122,98 @@ type Opts struct {
 
 	// PanicHandler triggered if panic happened.
 	// rcv: first param is argument retrieved from `recover()` function.
+	// If DebugPanics is true, second param is the stack trace in []byte.
 	PanicHandler PanicHandler
 
+	// DebugPanics include recovered value and stack trace into the default panic response.
+	// Useful in development, keep it false in production to not leak internals.
+	DebugPanics bool
+
 	// NotFoundHandler triggered if path not found.
 	// If empty then default is used.
 	NotFoundHandler http.HandlerFunc
+
+	// MethodNotAllowedHandler triggered if path is found but not for the request method.
+	// Allow header listing permitted methods is set before it is called.
+	// If empty then default 405 is used.
+	MethodNotAllowedHandler http.HandlerFunc
+
+	// ErrorHandler triggered if handler wrapped by Wrap returns error.
+	// If empty then default is used, which responds 500 without exposing the error.
+	ErrorHandler ErrorHandler
+
+	// StripPrefix optional, trimmed from request path before routing, e.g. when mounted behind reverse proxy at /service.
+	// Requests without the prefix are responded with not found.
+	StripPrefix string
+
+	// CleanPath clean request path before routing, collapsing duplicate slashes and resolving . and ..,
+	// e.g. /api//users/./1 is routed as /api/users/1. Opt-in.
+	CleanPath bool
+
+	// RedirectCleanPath redirect to the clean path instead of routing it directly, only if CleanPath is true.
+	// 301 for GET and HEAD, 308 for other methods.
+	RedirectCleanPath bool
+
+	// MaxURLLength maximum length of request URI (path and query), longer request is responded 414 URI Too Long
+	// before routing. If empty then no limit.
+	MaxURLLength int
+
+	// ConnState optional, called when a client connection changes state.
+	// See http.ConnState for the meaning of each state.
+	ConnState func(net.Conn, http.ConnState)
+
+	// BaseContext optional, return the base context of every request, e.g. carrying shared values like database pool,
+	// read by handlers from r.Context(). See http.Server.BaseContext.
+	BaseContext func(net.Listener) context.Context
+
+	// ConnContext optional, modify the context of a new connection derived from the base context.
+	// See http.Server.ConnContext.
+	ConnContext func(ctx context.Context, c net.Conn) context.Context
+
+	// InheritFD file descriptor of listener inherited from parent process, e.g. passed via Listener on graceful restart.
+	// If set, Start serves on it instead of binding Port. Unix only.
+	InheritFD int
+
+	// DisableRequestID disable generating request id for request without one, e.g. when upstream gateway always sets it.
+	// Existing Request-Id or X-Request-Id header is still propagated.
+	DisableRequestID bool
+
+	// MaxMultipartMemory maximum bytes of multipart form parsed by ParseMultipartForm kept in memory,
+	// the rest of file parts is stored in temporary files. If empty then 32MB.
+	MaxMultipartMemory int64
+
+	// HeadForGet serve HEAD request of path registered with GET only by running the GET handler without writing the body,
+	// Content-Length is still set to the body length. Explicitly registered HEAD route takes precedence. Opt-in.
+	HeadForGet bool
+
+	// PreStopDelay how long Shutdown waits after reporting not ready on ReadyPath before it stops accepting connections,
+	// giving load balancer time to take the server out of rotation. If empty then no delay.
+	PreStopDelay time.Duration
+
+	// KeepAlivePeriod TCP keep-alive period of accepted connections, negative to disable TCP keep-alive.
+	// If empty then Go default 15s is used. Applies to Start and RunTLSWithRedirect, not Run nor InheritFD.
+	// Note TCP_NODELAY, i.e. Nagle disabled, is already set by Go on every accepted connection.
+	KeepAlivePeriod time.Duration
+
+	// ListenControl optional, called on the listening socket before it is bound, to set socket options,
+	// e.g. SO_REUSEPORT via golang.org/x/sys/unix. See net.ListenConfig.Control, options are platform specific.
+	// Listen backlog is taken from the OS, e.g. net.core.somaxconn on Linux, and can not be set here.
+	// Applies to Start and RunTLSWithRedirect, not Run nor InheritFD.
+	ListenControl func(network, address string, c syscall.RawConn) error
+
+	// LogFile optional, log file with size based rotation written along with the other log destinations,
+	// e.g. to keep logs on host while still writing them into stderr. Written asynchronously, counted in Stats.DroppedLogs
+	// if its buffer is full.
+	LogFile *LogFileConfig
+
+	// NextProtos optional, protocols advertised via TLS ALPN in order of preference, overriding NextProtos of TLS,
+	// e.g. []string{"http/1.1"} to force HTTP/1.1. HTTP/2 is served only if "h2" is listed.
+	// If empty then HTTP/2 and HTTP/1.1 are negotiated as net/http does by default.
+	NextProtos []string
+
+	// DefaultHeaders optional, headers set into every response before handler runs, including not found and redirect,
+	// e.g. {"Server": "api", "Vary": "Origin"}. Handler can still override or delete them.
+	DefaultHeaders map[string]string
 }
 
 // Cors corst options
@@ -63,51 +225,78 @@ type Cors struct {
 	MaxAge           int
 	AllowCredentials bool
 	IsDebug          bool
+
+	// MethodCors optional, cors options of specific methods, e.g. permissive GET and restricted POST.
+	// Options of a method replace, not merge with, the options above, which apply to the other methods.
+	// Preflight request uses the options of its Access-Control-Request-Method.
+	MethodCors map[string]*Cors
 }
 
 func New(opts *Opts) *Server {
-	h := _router.New()
-	var cors *_cors.Cors
-	if opts.Cors != nil {
-		cors = _cors.New(_cors.Options{
-			AllowedOrigins:     opts.Cors.AllowedOrigins,
-			AllowedMethods:     opts.Cors.AllowedMethods,
-			AllowedHeaders:     opts.Cors.AllowedHeaders,
-			ExposedHeaders:     opts.Cors.ExposedHeaders,
-			MaxAge:             opts.Cors.MaxAge,
-			AllowCredentials:   opts.Cors.AllowCredentials,
-			OptionsPassthrough: true,
-			Debug:              opts.Cors.IsDebug,
-		})
-	}
-	var notFoundHandler http.Handler
+	srv := &Server{
+		port:               opts.Port,
+		idleTimeout:        opts.IdleTimeout,
+		logger:             log.New(os.Stderr, "", 0),
+		middlewares:        make([]Middleware, 0),
+		tls:                opts.TLS,
+		cors:               newCors(opts.Cors),
+		methodCors:         newMethodCors(opts.Cors),
+		errChan:            make(chan error),
+		panicHandler:       opts.PanicHandler,
+		debugPanics:        opts.DebugPanics,
+		errorHandler:       opts.ErrorHandler,
+		connState:          opts.ConnState,
+		baseContext:        opts.BaseContext,
+		connContext:        opts.ConnContext,
+		stripPrefix:        opts.StripPrefix,
+		maxURLLength:       opts.MaxURLLength,
+		cleanPath:          opts.CleanPath,
+		redirectCleanPath:  opts.RedirectCleanPath,
+		inheritFD:          opts.InheritFD,
+		disableRequestID:   opts.DisableRequestID,
+		maxMultipartMemory: opts.MaxMultipartMemory,
+		headForGet:         opts.HeadForGet,
+		preStopDelay:       opts.PreStopDelay,
+		keepAlivePeriod:    opts.KeepAlivePeriod,
+		listenControl:      opts.ListenControl,
+		nextProtos:         opts.NextProtos,
+		defaultHeaders:     copyHeaders(opts.DefaultHeaders),
+	}
 	if opts.NotFoundHandler != nil {
-		notFoundHandler = &notFound{opts.NotFoundHandler}
+		srv.notFoundHandler = &notFound{srv, opts.NotFoundHandler}
 	}
-	srv := &Server{
-		handlers:        h,
-		port:            opts.Port,
-		idleTimeout:     opts.IdleTimeout,
-		logger:          log.New(os.Stderr, "", 0),
-		middlewares:     make([]Middleware, 0),
-		tls:             opts.TLS,
-		cors:            cors,
-		errChan:         make(chan error),
-		panicHandler:    opts.PanicHandler,
-		notFoundHandler: notFoundHandler,
+	if opts.MethodNotAllowedHandler != nil {
+		srv.methodNotAllowedHandler = &notFound{srv, opts.MethodNotAllowedHandler}
 	}
+	srv.handlers = srv.newRouter()
 	if opts.EnableLogger {
-		w := make(buffer, 10<<20)
-		go write(w)
-		srv.logger = log.New(w, "", 0)
+		srv.logger = srv.asyncLogger()
 		srv.middlewares = append(srv.middlewares, srv.log)
 	}
+	if opts.AccessLogWriter != nil {
+		srv.accessLogger = log.New(opts.AccessLogWriter, "", 0)
+	}
+	if opts.ErrorLogWriter != nil {
+		if srv.accessLogger == nil {
+			// access log keeps the default destination.
+			srv.accessLogger = srv.logger
+		}
+		srv.logger = log.New(opts.ErrorLogWriter, "", 0)
+	}
+	if opts.LogFile != nil {
+		srv.withLogFile(opts.LogFile)
+	}
 	return srv
 }
 
 // Run the server. Blocking.
 func (s *Server) Run() {
 	s.logger.Printf("%s | httpserver | server is starting...", time.Now().Format(time.RFC3339))
+	if err := s.validateTLS(); err != nil {
+		s.logger.Printf("%s | httpserver | server failed with error: %v", time.Now().Format(time.RFC3339), err)
+		s.errChan <- err
+		return
+	}
 	s.logger.Printf("%s | httpserver | server is running on port %d", time.Now().Format(time.RFC3339), s.port)
 	if err := s.serve(); err != nil {
 		s.logger.Printf("%s | httpserver | server failed with error: %v", time.Now().Format(time.RFC3339), err)
@@ -115,16 +304,326 @@ func (s *Server) Run() {
 	}
 }
 
+// Start bind the listener synchronously and serve in background. Non-blocking.
+// Unlike Run, bind error (e.g. port already in use) is returned directly.
+// Error happened after the server started is sent into ListenError channel.
+// Graceful restart is not supported in this mode, hand off the listener via Listener and InheritFD instead.
+func (s *Server) Start() error {
+	if err := s.validateTLS(); err != nil {
+		s.logger.Printf("%s | httpserver | server failed with error: %v", time.Now().Format(time.RFC3339), err)
+		return err
+	}
+	s.serverMu.Lock()
+	ln, err := s.listen()
+	if err != nil {
+		s.serverMu.Unlock()
+		s.logger.Printf("%s | httpserver | server failed to bind with error: %v", time.Now().Format(time.RFC3339), err)
+		return err
+	}
+	srv := s.httpServer()
+	s.listener = ln
+	s.server = srv
+	s.serverMu.Unlock()
+	s.logger.Printf("%s | httpserver | server is running on %s", time.Now().Format(time.RFC3339), ln.Addr())
+	go func() {
+		var err error
+		if srv.TLSConfig != nil {
+			err = srv.ServeTLS(ln, "", "")
+		} else {
+			err = srv.Serve(ln)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			s.logger.Printf("%s | httpserver | server failed with error: %v", time.Now().Format(time.RFC3339), err)
+			s.errChan <- err
+		}
+	}()
+	return nil
+}
+
+// listen bind the port, or use the inherited listener if InheritFD is set.
+func (s *Server) listen() (net.Listener, error) {
+	if s.inheritFD <= 0 {
+		return s.listenTCP(s.port)
+	}
+	f := os.NewFile(uintptr(s.inheritFD), "httpserver-listener")
+	if f == nil {
+		return nil, fmt.Errorf("httpserver: invalid inherited fd %d", s.inheritFD)
+	}
+	defer f.Close()
+	return net.FileListener(f)
+}
+
+// listenTCP bind port with the configured keep-alive period and socket control.
+func (s *Server) listenTCP(port uint16) (net.Listener, error) {
+	lc := net.ListenConfig{KeepAlive: s.keepAlivePeriod, Control: s.listenControl}
+	return lc.Listen(context.Background(), "tcp", fmt.Sprintf(":%d", port))
+}
+
+// Listener return the active listener of server started via Start, or its https listener if started with redirect,
+// ErrNotStarted otherwise.
+// For zero-downtime restart, duplicate its file descriptor, e.g. ln.(*net.TCPListener).File(),
+// pass it into the new process and start it with InheritFD.
+func (s *Server) Listener() (net.Listener, error) {
+	s.serverMu.Lock()
+	defer s.serverMu.Unlock()
+	if s.listener == nil {
+		return nil, ErrNotStarted
+	}
+	return s.listener, nil
+}
+
+// Shutdown gracefully stop server started via Start, StartTLSWithRedirect or RunTLSWithRedirect, waiting for
+// active connections until they are idle or ctx is done. Returns ErrNotStarted if server is not started.
+// Server is set to not ready first and keeps serving for PreStopDelay, so readiness probe fails before it stops accepting.
+// PreStopDelay is spent out of ctx, so ctx must allow PreStopDelay plus the time to drain connections,
+// use ShutdownWithTimeout to give draining its own budget.
+func (s *Server) Shutdown(ctx context.Context) error {
+	srv, redirect := s.servers()
+	if srv == nil {
+		return ErrNotStarted
+	}
+	s.preStop(ctx)
+	if s.preStopDelay > 0 && ctx.Err() != nil {
+		s.logger.Printf("%s | httpserver | shutdown deadline reached within pre-stop delay %s, no time left to drain connections", time.Now().Format(time.RFC3339), s.preStopDelay)
+	}
+	return drain(ctx, srv, redirect)
+}
+
+// servers return the started server and plaintext redirect server, nil if not started.
+func (s *Server) servers() (srv, redirect *http.Server) {
+	s.serverMu.Lock()
+	defer s.serverMu.Unlock()
+	return s.server, s.redirectServer
+}
+
+// preStop set server not ready, then wait for PreStopDelay or until ctx is done.
+func (s *Server) preStop(ctx context.Context) {
+	s.SetReady(false)
+	if s.preStopDelay > 0 {
+		select {
+		case <-time.After(s.preStopDelay):
+		case <-ctx.Done():
+		}
+	}
+}
+
+// drain stop accepting connections of srv and optional redirect, and wait for active ones until they are idle
+// or ctx is done.
+func drain(ctx context.Context, srv, redirect *http.Server) error {
+	var redirectErr error
+	if redirect != nil {
+		redirectErr = redirect.Shutdown(ctx)
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		return err
+	}
+	return redirectErr
+}
+
+// ShutdownWithTimeout gracefully stop server like Shutdown, waiting at most grace after PreStopDelay,
+// then force close the connections still active, e.g. handler ignoring request context cancellation.
+// forced reports whether the connections had to be force closed.
+func (s *Server) ShutdownWithTimeout(grace time.Duration) (forced bool, err error) {
+	srv, redirect := s.servers()
+	if srv == nil {
+		return false, ErrNotStarted
+	}
+	s.preStop(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	err = drain(ctx, srv, redirect)
+	if err != context.DeadlineExceeded {
+		return false, err
+	}
+	s.logger.Printf("%s | httpserver | server did not stop within %s, force closing connections", time.Now().Format(time.RFC3339), grace)
+	if redirect != nil {
+		redirect.Close()
+	}
+	return true, srv.Close()
+}
+
+// Handler return the server as http.Handler with the full pipeline: cors, routing, middlewares,
+// panic recovery and request id. Useful to test handlers without binding a port,
+// e.g. with httptest.NewRecorder or httptest.NewServer.
+// Call it after all routes are registered.
+func (s *Server) Handler() http.Handler {
+	s.routing.Store(&routing{handlers: s.handlers, hostRouters: s.hostRouters})
+	handler := s.routerHandler()
+	if s.headForGet {
+		handler = s.headHandler(handler)
+	}
+	if s.stripPrefix != "" {
+		handler = s.stripPrefixHandler(handler)
+	}
+	if s.cleanPath {
+		handler = s.cleanPathHandler(handler)
+	}
+	if s.cors != nil || len(s.methodCors) > 0 {
+		handler = s.corsHandler(handler)
+	}
+	if s.maxURLLength > 0 {
+		handler = s.maxURLLengthHandler(handler)
+	}
+	handler = s.readyHandler(handler)
+	if len(s.defaultHeaders) > 0 {
+		handler = s.defaultHeadersHandler(handler)
+	}
+	return s.countHandler(handler)
+}
+
+// newRouter return empty router responding not found and method not allowed by the server handlers,
+// which read the server configuration per request, so router needs no configuring once it is served.
+func (s *Server) newRouter() *_router.Router {
+	h := _router.New()
+	h.NotFound = http.HandlerFunc(s.notFound)
+	h.MethodNotAllowed = http.HandlerFunc(s.methodNotAllowed)
+	return h
+}
+
+// stripPrefixHandler trim s.stripPrefix from request path before passing it into next,
+// request without the prefix is responded by not found handler.
+func (s *Server) stripPrefixHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := strings.TrimPrefix(r.URL.Path, s.stripPrefix)
+		if len(p) == len(r.URL.Path) || (p != "" && p[0] != '/') {
+			s.notFound(w, r)
+			return
+		}
+		if p == "" {
+			p = "/"
+		}
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = p
+		r2.URL.RawPath = ""
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// cleanPathHandler clean request path, collapsing duplicate slashes and resolving . and .. without going above root,
+// before passing it into next, or redirect to the clean path if s.redirectCleanPath.
+func (s *Server) cleanPathHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := _router.CleanPath(r.URL.Path)
+		if p == r.URL.Path {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if s.redirectCleanPath {
+			u := *r.URL
+			u.Path = p
+			u.RawPath = ""
+			code := http.StatusMovedPermanently
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				code = http.StatusPermanentRedirect
+			}
+			http.Redirect(w, r, u.RequestURI(), code)
+			return
+		}
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = p
+		r2.URL.RawPath = ""
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// maxURLLengthHandler respond 414 URI Too Long if request URI (path and query) is longer than s.maxURLLength.
+func (s *Server) maxURLLengthHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.URL.RequestURI()) > s.maxURLLength {
+			http.Error(w, http.StatusText(http.StatusRequestURITooLong), http.StatusRequestURITooLong)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// methodNotAllowed respond with method not allowed handler if set, otherwise default 405.
+// Allow header is already set by router, HEAD is added into it for GET route if HeadForGet.
+func (s *Server) methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	if s.headForGet {
+		allowed := strings.Split(w.Header().Get("Allow"), ", ")
+		if containsString(allowed, http.MethodGet) && !containsString(allowed, http.MethodHead) {
+			allowed = append(allowed, http.MethodHead)
+			sort.Strings(allowed)
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+		}
+	}
+	if s.methodNotAllowedHandler != nil {
+		s.methodNotAllowedHandler.ServeHTTP(w, r)
+		return
+	}
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// notFound respond with not found handler if set, otherwise default 404.
+func (s *Server) notFound(w http.ResponseWriter, r *http.Request) {
+	if s.notFoundHandler != nil {
+		s.notFoundHandler.ServeHTTP(w, r)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// TestServer start httptest.Server serving Handler for integration tests, base URL is in its URL field.
+// Caller must Close it when done, e.g.
+//
+//	ts := srv.TestServer()
+//	defer ts.Close()
+//	resp, err := http.Get(ts.URL + "/path")
+func (s *Server) TestServer() *httptest.Server {
+	return httptest.NewServer(s.Handler())
+}
+
+// httpServer build the underlying http.Server from the server configuration.
+func (s *Server) httpServer() *http.Server {
+	srv := &http.Server{
+		Addr:        fmt.Sprintf(":%d", s.port),
+		Handler:     s.Handler(),
+		IdleTimeout: s.idleTimeout,
+		TLSConfig:   s.tls,
+		ConnState:   s.trackConn,
+		BaseContext: s.baseContext,
+		ConnContext: s.withConn,
+	}
+	if s.tls != nil && len(s.nextProtos) > 0 {
+		srv.TLSConfig = s.tls.Clone()
+		srv.TLSConfig.NextProtos = s.nextProtos
+		if !containsString(s.nextProtos, "h2") {
+			// non-nil TLSNextProto stops net/http from adding h2 and serving http/2.
+			srv.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+		}
+	}
+	return srv
+}
+
 func (s *Server) ListenError() <-chan error {
 	return s.errChan
 }
 
+// notFound adapt custom not found and method not allowed handlers into http.Handler served by router.
 type notFound struct {
+	srv     *Server
 	handler http.HandlerFunc
 }
 
 func (n *notFound) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	n.handler(w, r)
+	// through handle so the handler gets request id and can use the Response helpers.
+	handle(n.handler, "", !n.srv.disableRequestID)(w, r, nil)
 }
 
 // TLSConfig generate certificate config using provided certificate and private key.
@@ -132,7 +631,7 @@ func (n *notFound) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 func (s *Server) TLSConfig(cert, key string) error {
 	certificate, err := tls.LoadX509KeyPair(cert, key)
 	if err != nil {
-		return err
+		return fmt.Errorf("httpserver: failed to load tls certificate %s and key %s: %w", cert, key, err)
 	}
 	s.tls = &tls.Config{
 		Certificates: []tls.Certificate{certificate},
@@ -140,11 +639,41 @@ func (s *Server) TLSConfig(cert, key string) error {
 	return nil
 }
 
+// validateTLS report misconfigured TLS config early, before serving, instead of failing on the first handshake
+// or deep in ServeTLS with obscure error.
+func (s *Server) validateTLS() error {
+	if s.tls == nil || s.tls.GetCertificate != nil || s.tls.GetConfigForClient != nil {
+		return nil
+	}
+	if len(s.tls.Certificates) == 0 {
+		return ErrNoCertificate
+	}
+	for i, c := range s.tls.Certificates {
+		if len(c.Certificate) == 0 || c.PrivateKey == nil {
+			return fmt.Errorf("httpserver: tls certificate %d has no certificate or private key", i)
+		}
+	}
+	return nil
+}
+
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
 	requestID  string
 	xRequestID string
+
+	// panicHooks registered by WithRecover, called by recoverPanic.
+	panicHooks []func(rcv interface{})
+	// errorHooks registered by OnError, called by recoverPanic once the panic is responded.
+	errorHooks []func()
+
+	// bytesWritten number of body bytes actually sent, and writeErr first error writing them,
+	// e.g. broken pipe when client has gone away.
+	bytesWritten int64
+	writeErr     error
+
+	// noLog set by NoLog to skip access log of the request.
+	noLog bool
 }
 
 func (rw *responseWriter) WriteHeader(statusCode int) {
@@ -152,14 +681,75 @@ func (rw *responseWriter) WriteHeader(statusCode int) {
 	rw.ResponseWriter.WriteHeader(statusCode)
 }
 
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(p)
+	rw.bytesWritten += int64(n)
+	if err != nil && rw.writeErr == nil {
+		rw.writeErr = err
+	}
+	return n, err
+}
+
+// Unwrap return the underlying http.ResponseWriter, so http.ResponseController can reach its
+// deadlines and flushing through responseWriter.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Flush flush the underlying http.ResponseWriter if it is http.Flusher, so handler can stream through responseWriter.
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Push forward http/2 server push into the underlying http.ResponseWriter, http.ErrNotSupported if it does not support it.
+func (rw *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := rw.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// StatusCode return status code responded so far, 200 if not set explicitly.
+func (rw *responseWriter) StatusCode() int {
+	return rw.statusCode
+}
+
+// BytesWritten return number of body bytes sent so far.
+func (rw *responseWriter) BytesWritten() int64 {
+	return rw.bytesWritten
+}
+
 func newResponseWriter(w http.ResponseWriter, reqID string, xReqID string) *responseWriter {
 	// default if not set is 200
-	return &responseWriter{w, http.StatusOK, reqID, xReqID}
+	return &responseWriter{ResponseWriter: w, statusCode: http.StatusOK, requestID: reqID, xRequestID: xReqID}
+}
+
+// lookupResponseWriter find *responseWriter inside w, unwrapping writers wrapped by middlewares.
+func lookupResponseWriter(w http.ResponseWriter) (*responseWriter, bool) {
+	for {
+		switch t := w.(type) {
+		case *responseWriter:
+			return t, true
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = t.Unwrap()
+		default:
+			return nil, false
+		}
+	}
 }
 
 func f(next http.HandlerFunc) _router.Handle {
+	return handle(next, "", true)
+}
+
+// handle adapt next into router handle, propagating request id into headers and context,
+// generating one if generateRequestID and request has none.
+// @route: route pattern put into context, read by RoutePattern. Empty if not serving registered route.
+func handle(next http.HandlerFunc, route string, generateRequestID bool) _router.Handle {
 	return func(w http.ResponseWriter, r *http.Request, ps _router.Params) {
-		if r.Header.Get("Request-Id") == "" && r.Header.Get("X-Request-Id") == "" {
+		if generateRequestID && r.Header.Get("Request-Id") == "" && r.Header.Get("X-Request-Id") == "" {
 			r.Header.Set("Request-Id", _uuid.New().String())
 		}
 		if r.Header.Get("Request-Id") == "" && r.Header.Get("X-Request-Id") != "" {
@@ -172,6 +762,11 @@ func f(next http.HandlerFunc) _router.Handle {
 			}
 			r.URL.RawQuery = urlValues.Encode()
 		}
+		ctx := context.WithValue(r.Context(), requestIDKey, r.Header.Get("Request-Id"))
+		if route != "" {
+			ctx = context.WithValue(ctx, routeKey, route)
+		}
+		r = r.WithContext(ctx)
 		rw := newResponseWriter(w, r.Header.Get("Request-Id"), r.Header.Get("X-Request-Id"))
 		next(rw, r)
 	}
@@ -181,15 +776,39 @@ func (s *Server) recoverPanic(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if rcv := recover(); rcv != nil {
-				if s.panicHandler != nil {
+				if rcv == http.ErrAbortHandler {
+					// deliberate abort, re-panic so net/http aborts the response silently as it does without us.
+					panic(rcv)
+				}
+				// captured once here, so it points to the panicking handler and is logged through s.logger.
+				stack := debug.Stack()
+				if p, ok := rcv.(*stackedPanic); ok { // re-panicked from other goroutine, e.g. by Timeout.
+					rcv, stack = p.value, p.stack
+				}
+				if rw, ok := lookupResponseWriter(w); ok {
+					for _, hook := range rw.panicHooks {
+						hook(rcv)
+					}
+				}
+				switch {
+				case s.panicHandler != nil && s.debugPanics:
+					s.panicHandler(w, r, rcv, stack)
+				case s.panicHandler != nil:
 					s.panicHandler(w, r, rcv)
-				} else {
+				case s.debugPanics:
+					ResponseString(w, http.StatusInternalServerError, fmt.Sprintf("httpserver got panic: %v\n\n%s", rcv, stack))
+				default:
 					ResponseString(w, http.StatusInternalServerError, "httpserver got panic")
 				}
-				s.logger.Printf("%s | httpserver | %s | %s | %s | %s\n", time.Now().Format(time.RFC3339), "PANIC", r.Method, r.URL.Path, r.Header.Get("Request-Id"))
-				s.logger.Printf("☠️ ☠️ ☠️ ☠️ ☠️ ☠️  PANIC START (%s) ☠️ ☠️ ☠️ ☠️ ☠️ ☠️", r.Header.Get("Request-Id"))
-				debug.PrintStack()
-				s.logger.Printf("☠️ ☠️ ☠️ ☠️ ☠️ ☠️  PANIC END (%s) ☠️ ☠️ ☠️ ☠️ ☠️ ☠️", r.Header.Get("Request-Id"))
+				if rw, ok := lookupResponseWriter(w); ok {
+					for _, hook := range rw.errorHooks {
+						hook()
+					}
+				}
+				// single entry, so the stack is not interleaved with logs of concurrent requests.
+				id := r.Header.Get("Request-Id")
+				s.logger.Printf("%s | httpserver | %s | %s | %s | %s | %v\n☠️ ☠️ ☠️ ☠️ ☠️ ☠️  PANIC START (%s) ☠️ ☠️ ☠️ ☠️ ☠️ ☠️\n%s☠️ ☠️ ☠️ ☠️ ☠️ ☠️  PANIC END (%s) ☠️ ☠️ ☠️ ☠️ ☠️ ☠️\n",
+					time.Now().Format(time.RFC3339), "PANIC", r.Method, r.URL.Path, id, rcv, id, stack, id)
 				return
 			}
 		}()
@@ -197,48 +816,269 @@ func (s *Server) recoverPanic(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-func (s *Server) GET(path string, handler http.HandlerFunc, middlewares ...Middleware) {
-	s.handlers.GET(path, f(s.recoverPanic(s.chainMiddlewares(handler, middlewares...))))
+// WithRecover middleware to register panic hook for the route.
+// Hook is called with the request and the recovered value before the panic is responded and logged,
+// useful to log route specific context, e.g. user id.
+func WithRecover(hook func(r *http.Request, recovered interface{})) Middleware {
+	return func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if rw, ok := lookupResponseWriter(w); ok {
+				rw.panicHooks = append(rw.panicHooks, func(rcv interface{}) {
+					hook(r, rcv)
+				})
+			}
+			next(w, r)
+		}
+	}
+}
+
+// OnError middleware to call handler once the route responded with error status, 4xx or 5xx,
+// useful to report errors, e.g. to Sentry. Panics are reported too, after the server responded them.
+func OnError(handler func(r *http.Request, status int)) Middleware {
+	return func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			rw, ok := lookupResponseWriter(w)
+			if !ok {
+				next(w, r)
+				return
+			}
+			report := func() {
+				if rw.statusCode >= http.StatusBadRequest {
+					handler(r, rw.statusCode)
+				}
+			}
+			// next does not return on panic, so report is called by recoverPanic instead.
+			rw.errorHooks = append(rw.errorHooks, report)
+			next(w, r)
+			report()
+		}
+	}
+}
+
+// ResponseInfo http.ResponseWriter passed into After hook, exposing what has been responded.
+type ResponseInfo interface {
+	http.ResponseWriter
+	StatusCode() int
+	BytesWritten() int64
+}
+
+// After middleware to call fn after the handler completed, with the final status and body size, useful for post-processing
+// e.g. metrics per status. Headers set in fn are not sent if handler has already written the body, use http.TrailerPrefix for those.
+// fn is not called if the response is not tracked by the server, e.g. handler invoked outside of registered routes.
+func After(fn func(w ResponseInfo, r *http.Request)) Middleware {
+	return func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			next(w, r)
+			if rw, ok := lookupResponseWriter(w); ok {
+				fn(rw, r)
+			}
+		}
+	}
+}
+
+func (s *Server) GET(path string, handler http.HandlerFunc, middlewares ...Middleware) *Route {
+	return s.register(http.MethodGet, path, handler, middlewares...)
+}
+
+func (s *Server) HEAD(path string, handler http.HandlerFunc, middlewares ...Middleware) *Route {
+	return s.register(http.MethodHead, path, handler, middlewares...)
+}
+
+func (s *Server) POST(path string, handler http.HandlerFunc, middlewares ...Middleware) *Route {
+	return s.register(http.MethodPost, path, handler, middlewares...)
+}
+
+func (s *Server) PUT(path string, handler http.HandlerFunc, middlewares ...Middleware) *Route {
+	return s.register(http.MethodPut, path, handler, middlewares...)
+}
+
+func (s *Server) DELETE(path string, handler http.HandlerFunc, middlewares ...Middleware) *Route {
+	return s.register(http.MethodDelete, path, handler, middlewares...)
+}
+
+func (s *Server) PATCH(path string, handler http.HandlerFunc, middlewares ...Middleware) *Route {
+	return s.register(http.MethodPatch, path, handler, middlewares...)
+}
+
+func (s *Server) OPTIONS(path string, handler http.HandlerFunc, middlewares ...Middleware) *Route {
+	return s.register(http.MethodOptions, path, handler, middlewares...)
+}
+
+// Method register handler for any method, e.g. TRACE, CONNECT or WebDAV methods like REPORT and PROPFIND.
+func (s *Server) Method(method string, path string, handler http.HandlerFunc, middlewares ...Middleware) *Route {
+	return s.register(method, path, handler, middlewares...)
+}
+
+// GlobalOPTIONS set handler of automatic OPTIONS response, for path without explicitly registered OPTIONS route,
+// e.g. to add Access-Control-Max-Age header. Allow header listing permitted methods is set before it is called.
+// If not set then empty 200 is responded.
+func (s *Server) GlobalOPTIONS(handler http.HandlerFunc) {
+	s.handlers.GlobalOPTIONS = &notFound{s, handler}
+	for _, h := range s.hostRouters {
+		h.GlobalOPTIONS = s.handlers.GlobalOPTIONS
+	}
+}
+
+// Route registered route information, returned by route registration to attach metadata, e.g.
+//
+//	srv.GET("/users/:id", getUser).WithSummary("Get user by id").WithTags("users")
+//
+// Breaking change: registration methods, e.g. GET, FILES and those of Group, used to return nothing. Code storing them
+// as func(string, http.HandlerFunc, ...Middleware) values no longer compiles, wrap them in a func literal instead:
+//
+//	get := func(path string, h http.HandlerFunc, mws ...httpserver.Middleware) { srv.GET(path, h, mws...) }
+type Route struct {
+	Method string
+	Path   string
+	// Host of route registered via HostGroup, empty for the others.
+	Host string
+
+	// Summary and Tags optional metadata for introspection and api docs.
+	Summary string
+	Tags    []string
+}
+
+// WithSummary set summary of the route.
+func (rt *Route) WithSummary(summary string) *Route {
+	rt.Summary = summary
+	return rt
 }
 
-func (s *Server) HEAD(path string, handler http.HandlerFunc, middlewares ...Middleware) {
-	s.handlers.HEAD(path, f(s.recoverPanic(s.chainMiddlewares(handler, middlewares...))))
+// WithTags add tags of the route.
+func (rt *Route) WithTags(tags ...string) *Route {
+	rt.Tags = append(rt.Tags, tags...)
+	return rt
+}
+
+// Routes return all registered routes in registration order.
+func (s *Server) Routes() []Route {
+	routes := make([]Route, len(s.routes))
+	for i := range s.routes {
+		routes[i] = *s.routes[i]
+	}
+	return routes
 }
 
-func (s *Server) POST(path string, handler http.HandlerFunc, middlewares ...Middleware) {
-	s.handlers.POST(path, f(s.recoverPanic(s.chainMiddlewares(handler, middlewares...))))
+// Lookup report whether a route matches method and path without serving it, along with path params,
+// e.g. for authorization decisions before handling. Path is matched as routed, after StripPrefix if any.
+func (s *Server) Lookup(method string, path string) (matched bool, params map[string]string) {
+	handle, ps, _ := s.handlers.Lookup(method, path)
+	if handle == nil {
+		return false, nil
+	}
+	params = make(map[string]string, len(ps))
+	for _, p := range ps {
+		params[p.Key] = p.Value
+	}
+	return true, params
 }
 
-func (s *Server) PUT(path string, handler http.HandlerFunc, middlewares ...Middleware) {
-	s.handlers.PUT(path, f(s.recoverPanic(s.chainMiddlewares(handler, middlewares...))))
+// ResetRoutes remove all registered routes, keeping the server configuration, middlewares and router options
+// e.g. GlobalOPTIONS. Useful to re-register routes per case in table driven tests.
+// Handler returned before reset keeps serving the old routes until Handler is called again.
+// Use UpdateRoutes instead to replace routes of running server.
+func (s *Server) ResetRoutes() {
+	s.handlers = copyRouter(s.handlers)
+	s.routes = nil
+	s.routeMiddlewares = nil
+	s.hostRouters = nil
 }
 
-func (s *Server) DELETE(path string, handler http.HandlerFunc, middlewares ...Middleware) {
-	s.handlers.DELETE(path, f(s.recoverPanic(s.chainMiddlewares(handler, middlewares...))))
+// copyRouter return empty router with the options of old.
+func copyRouter(old *_router.Router) *_router.Router {
+	h := _router.New()
+	h.RedirectTrailingSlash = old.RedirectTrailingSlash
+	h.RedirectFixedPath = old.RedirectFixedPath
+	h.HandleMethodNotAllowed = old.HandleMethodNotAllowed
+	h.HandleOPTIONS = old.HandleOPTIONS
+	h.GlobalOPTIONS = old.GlobalOPTIONS
+	h.NotFound = old.NotFound
+	h.MethodNotAllowed = old.MethodNotAllowed
+	h.PanicHandler = old.PanicHandler
+	return h
 }
 
-func (s *Server) PATCH(path string, handler http.HandlerFunc, middlewares ...Middleware) {
-	s.handlers.PATCH(path, f(s.recoverPanic(s.chainMiddlewares(handler, middlewares...))))
+// register register route into router of the server itself, see registerHost.
+func (s *Server) register(method string, path string, handler http.HandlerFunc, middlewares ...Middleware) *Route {
+	return s.registerHost("", method, path, handler, middlewares...)
 }
 
-func (s *Server) OPTIONS(path string, handler http.HandlerFunc, middlewares ...Middleware) {
-	s.handlers.OPTIONS(path, f(s.recoverPanic(s.chainMiddlewares(handler, middlewares...))))
+// registerHost is the single entry for every route registration, both from Server and Group.
+// It records the route and wraps handler with middlewares chain, panic recovery and request id.
+// @host: host of group created by HostGroup, empty for routes of the server itself.
+// @path: full path including group prefix if any.
+// @middlewares: route middlewares including group middlewares if any, server middlewares are applied outside of them.
+// It panics naming the route if it conflicts with registered one.
+func (s *Server) registerHost(host string, method string, path string, handler http.HandlerFunc, middlewares ...Middleware) *Route {
+	key := method + " " + path
+	if host != "" {
+		key = host + " " + key
+	}
+	if _, ok := s.routeMiddlewares[key]; ok {
+		panic(fmt.Sprintf("httpserver: route %s %s%s is already registered", method, host, path))
+	}
+	defer func() {
+		if rcv := recover(); rcv != nil {
+			panic(fmt.Sprintf("httpserver: failed to register route %s %s: %v", method, path, rcv))
+		}
+	}()
+	middlewares = s.middlewareChain(middlewares)
+	// recoverPanic wraps the whole chain, so panic of any server, group or route middleware is recovered too.
+	s.hostRouter(host).Handle(method, path, handle(s.withServer(s.recoverPanic(Chain(handler, middlewares...))), path, !s.disableRequestID))
+	route := &Route{Method: method, Path: path, Host: host}
+	s.routes = append(s.routes, route)
+	if s.routeMiddlewares == nil {
+		s.routeMiddlewares = make(map[string][]Middleware)
+	}
+	s.routeMiddlewares[key] = middlewares
+	return route
 }
 
 // FILES serve files from 1 directory dynamically.
 // @filePath: must end with '/*filepath' as placeholder for filename to be accessed.
 // @rootPath: root directory where @filepath locate.
-func (s *Server) FILES(filePath string, rootPath string, middlewares ...Middleware) {
+func (s *Server) FILES(filePath string, rootPath string, middlewares ...Middleware) *Route {
+	return s.FILESWith(filePath, rootPath, nil, middlewares...)
+}
+
+// FILESWith serve files like FILES with options. nil opts is the same as FILES.
+func (s *Server) FILESWith(filePath string, rootPath string, opts *FilesOpts, middlewares ...Middleware) *Route {
+	return s.GET(filePath, filesHandler(filePath, rootPath, opts), middlewares...)
+}
+
+// FILE serve single file at exact urlPath, e.g. /favicon.ico, via http.ServeFile.
+// @urlPath: route of the file, without catch-all.
+// @filePath: path of the file to be served.
+func (s *Server) FILE(urlPath string, filePath string, middlewares ...Middleware) *Route {
+	return s.GET(urlPath, fileHandler(filePath), middlewares...)
+}
+
+func fileHandler(filePath string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, filePath)
+	}
+}
 
+func filesHandler(filePath string, rootPath string, opts *FilesOpts) http.HandlerFunc {
 	if len(filePath) < 10 || filePath[len(filePath)-10:] != "/*filepath" {
 		panic("path must end with /*filepath in path '" + filePath + "'")
 	}
+	if opts == nil {
+		opts = &FilesOpts{}
+	}
 
-	rootDir := http.Dir(rootPath)
+	var rootDir http.FileSystem = http.Dir(rootPath)
+	if opts.DisableListing || (opts.Index != "" && opts.Index != defaultIndex) {
+		index := opts.Index
+		if index == "" {
+			index = defaultIndex
+		}
+		rootDir = filesFS{fs: rootDir, index: index, noListing: opts.DisableListing}
+	}
 	fileServer := http.FileServer(rootDir)
 
-	s.GET(filePath, func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
 		r.URL.Path = r.URL.Query().Get("filepath")
 		fileServer.ServeHTTP(w, r)
-	}, middlewares...)
+	}
 }
@@ -1,11 +1,24 @@
 package httpserver
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	_router "github.com/julienschmidt/httprouter"
 )
@@ -18,7 +31,7 @@ var (
 
 func newServer() *Server {
 	w := make(buffer, 10)
-	go write(w)
+	go writeTo(w, os.Stderr)
 	cors := &Cors{}
 	srv := New(&Opts{
 		Port:            8080,
@@ -49,6 +62,238 @@ func TestListenError(t *testing.T) {
 	srv.errChan <- fmt.Errorf("error")
 }
 
+func TestStart_BindError(t *testing.T) {
+	srv1 := New(&Opts{Port: 0})
+	if err := srv1.Start(); err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	defer srv1.server.Close()
+
+	srv2 := New(&Opts{Port: uint16(srv1.listener.Addr().(*net.TCPAddr).Port)})
+	err := srv2.Start()
+	if err == nil {
+		srv2.server.Close()
+		t.Fatalf("%s expected bind error, return null", t.Name())
+	}
+	if !strings.Contains(err.Error(), "address already in use") {
+		t.Errorf("%s expected address already in use error, returned %v", t.Name(), err)
+	}
+}
+
+func TestShutdownWithTimeout(t *testing.T) {
+	srv := New(&Opts{})
+	if _, err := srv.ShutdownWithTimeout(time.Second); err != ErrNotStarted {
+		t.Errorf("%s expected %v, returned %v", t.Name(), ErrNotStarted, err)
+	}
+
+	entered, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	srv.GET("/stuck", func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release // ignores request context cancellation
+	})
+	if err := srv.Start(); err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	go http.Get(fmt.Sprintf("http://%s/stuck", srv.listener.Addr()))
+	<-entered
+
+	grace := 50 * time.Millisecond
+	start := time.Now()
+	forced, err := srv.ShutdownWithTimeout(grace)
+	if !forced || err != nil {
+		t.Errorf("%s expected forced close with null error, returned %v %v", t.Name(), forced, err)
+	}
+	if elapsed := time.Since(start); elapsed < grace {
+		t.Errorf("%s expected forced close after %v, returned %v", t.Name(), grace, elapsed)
+	}
+}
+
+func TestConnState(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		states = make(map[http.ConnState]bool)
+	)
+	srv := New(&Opts{
+		ConnState: func(c net.Conn, cs http.ConnState) {
+			mu.Lock()
+			states[cs] = true
+			mu.Unlock()
+		},
+	})
+	srv.GET("/conn", func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusOK, "ok")
+	})
+	if err := srv.Start(); err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	defer srv.server.Close()
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/conn", srv.listener.Addr()))
+	if err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !states[http.StateNew] || !states[http.StateActive] {
+		t.Errorf("%s expected StateNew and StateActive observed, returned %v", t.Name(), states)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	srv := New(&Opts{
+		Cors: &Cors{AllowedOrigins: []string{"http://example.com"}},
+		NotFoundHandler: func(w http.ResponseWriter, r *http.Request) {
+			ResponseString(w, http.StatusNotFound, "not found")
+		},
+	})
+	srv.GET("/hello/:name", func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusOK, "hello "+r.URL.Query().Get("name"))
+	})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/hello/gopher", nil)
+	req.Header.Set("Origin", "http://example.com")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "hello gopher" {
+		t.Errorf("%s expected %d hello gopher, returned %d %s", t.Name(), http.StatusOK, resp.StatusCode, body)
+	}
+	if resp.Header.Get("Request-Id") == "" {
+		t.Errorf("%s expected Header Request-Id not empty, found empty", t.Name())
+	}
+	if resp.Header.Get("Access-Control-Allow-Origin") != "http://example.com" {
+		t.Errorf("%s expected cors header, returned %v", t.Name(), resp.Header)
+	}
+
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/unknown", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusNotFound, w.Code)
+	}
+}
+
+func TestStripPrefix(t *testing.T) {
+	srv := New(&Opts{StripPrefix: "/service"})
+	srv.GET("/users", func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusOK, "users")
+	})
+	srv.GET("/", func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusOK, "root")
+	})
+	tests := map[string]int{
+		"/service/users": http.StatusOK,
+		"/service":       http.StatusOK,
+		"/users":         http.StatusNotFound,
+		"/serviceusers":  http.StatusNotFound,
+	}
+	for path, code := range tests {
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != code {
+			t.Errorf("%s %s expected %d, returned %d", t.Name(), path, code, w.Code)
+		}
+	}
+}
+
+func TestMaxURLLength(t *testing.T) {
+	srv := New(&Opts{MaxURLLength: 20})
+	srv.GET("/search", func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusOK, "ok")
+	})
+	tests := map[string]int{
+		"/search?q=short":                      http.StatusOK,
+		"/search?q=" + strings.Repeat("a", 20): http.StatusRequestURITooLong,
+	}
+	for path, code := range tests {
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != code {
+			t.Errorf("%s %s expected %d, returned %d", t.Name(), path, code, w.Code)
+		}
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	srv := New(&Opts{CleanPath: true})
+	srv.GET("/api/users", func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusOK, "users")
+	})
+	srv.GET("/etc/passwd", func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusOK, "passwd")
+	})
+	tests := map[string]string{
+		"/api//users":                    "users",
+		"/api/./users":                   "users",
+		"/api/x/../users":                "users",
+		"/../../../etc/passwd":           "passwd",
+		"/api/users/../../../etc/passwd": "passwd",
+	}
+	for path, body := range tests {
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK || w.Body.String() != body {
+			t.Errorf("%s %s expected %d %s, returned %d %s", t.Name(), path, http.StatusOK, body, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestCleanPath_Redirect(t *testing.T) {
+	srv := New(&Opts{CleanPath: true, RedirectCleanPath: true})
+	srv.GET("/api/users", func(w http.ResponseWriter, r *http.Request) {})
+	srv.POST("/api/users", func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := map[string]int{
+		http.MethodGet:  http.StatusMovedPermanently,
+		http.MethodPost: http.StatusPermanentRedirect,
+	}
+	for method, code := range tests {
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, httptest.NewRequest(method, "/api//users?q=1", nil))
+		if w.Code != code || w.Header().Get("Location") != "/api/users?q=1" {
+			t.Errorf("%s %s expected %d to /api/users?q=1, returned %d %s", t.Name(), method, code, w.Code, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestTestServer(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GET("/ok", func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusOK, "ok")
+	})
+	srv.GET("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("test panic")
+	})
+	ts := srv.TestServer()
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/ok")
+	if err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Request-Id") == "" {
+		t.Errorf("%s expected %d with Request-Id, returned %d %v", t.Name(), http.StatusOK, resp.StatusCode, resp.Header)
+	}
+
+	resp, err = http.Get(ts.URL + "/panic")
+	if err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusInternalServerError, resp.StatusCode)
+	}
+}
+
 func TestWriteHeader(t *testing.T) {
 	w := &httptest.ResponseRecorder{}
 	rw := &responseWriter{ResponseWriter: w}
@@ -109,29 +354,582 @@ func TestRecoverPanic(t *testing.T) {
 	h(w, r)
 }
 
+func TestRecoverPanic_DebugPanics(t *testing.T) {
+	next := func(w http.ResponseWriter, r *http.Request) {
+		panic("debug me")
+	}
+	r, _ := http.NewRequest("GET", "/health-check", nil)
+
+	w := httptest.NewRecorder()
+	srv := New(&Opts{DebugPanics: true})
+	srv.recoverPanic(next)(newResponseWriter(w, "", ""), r)
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "debug me") || !strings.Contains(w.Body.String(), "goroutine") {
+		t.Errorf("%s expected panic value and stack in body, returned %d %s", t.Name(), w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	srv = New(&Opts{})
+	srv.recoverPanic(next)(newResponseWriter(w, "", ""), r)
+	if strings.Contains(w.Body.String(), "debug me") {
+		t.Errorf("%s expected no panic value in body, returned %s", t.Name(), w.Body.String())
+	}
+
+	var rcvs []interface{}
+	srv = New(&Opts{
+		DebugPanics: true,
+		PanicHandler: func(w http.ResponseWriter, r *http.Request, rcv ...interface{}) {
+			rcvs = rcv
+		},
+	})
+	srv.recoverPanic(next)(newResponseWriter(httptest.NewRecorder(), "", ""), r)
+	if len(rcvs) != 2 || rcvs[0] != "debug me" {
+		t.Errorf("%s expected recovered value and stack, returned %v", t.Name(), rcvs)
+	}
+}
+
+func TestRecoverPanic_StackLogged(t *testing.T) {
+	var buf bytes.Buffer
+	srv := New(&Opts{})
+	srv.logger = log.New(&buf, "", 0)
+	srv.GET("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("test panic")
+	})
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "goroutine") {
+		t.Errorf("%s expected %d without stack, returned %d %s", t.Name(), http.StatusInternalServerError, w.Code, w.Body.String())
+	}
+	logged := buf.String()
+	if !strings.Contains(logged, "PANIC START") || !strings.Contains(logged, "goroutine") || !strings.Contains(logged, "TestRecoverPanic_StackLogged") {
+		t.Errorf("%s expected stack of panicking handler in logger output, returned %s", t.Name(), logged)
+	}
+}
+
+// countWriter count Write calls, each call of log.Logger is one entry.
+type countWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	cw.writes++
+	return cw.Buffer.Write(p)
+}
+
+func TestRecoverPanic_Middleware(t *testing.T) {
+	panicking := func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			panic("middleware panic")
+		}
+	}
+	srv := New(&Opts{})
+	srv.logger = log.New(ioutil.Discard, "", 0)
+	srv.GET("/route", okHandler, panicking)
+	srv.Group("/group", panicking).GET("/route", okHandler)
+	srv.Use(panicking)
+	srv.GET("/server", okHandler)
+
+	for _, path := range []string{"/route", "/group/route", "/server"} {
+		w := httptest.NewRecorder()
+		srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("%s %s expected %d, returned %d", t.Name(), path, http.StatusInternalServerError, w.Code)
+		}
+	}
+}
+
+func TestRecoverPanic_SingleEntry(t *testing.T) {
+	cw := &countWriter{}
+	srv := New(&Opts{})
+	srv.logger = log.New(cw, "", 0)
+	srv.GET("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("test panic")
+	})
+	r := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	r.Header.Set("Request-Id", "test-id")
+	srv.handlers.ServeHTTP(httptest.NewRecorder(), r)
+
+	if cw.writes != 1 {
+		t.Errorf("%s expected %d log entry, returned %d", t.Name(), 1, cw.writes)
+	}
+	logged := cw.String()
+	for _, s := range []string{"| PANIC | GET | /panic | test-id | test panic", "goroutine", "TestRecoverPanic_SingleEntry", "PANIC END (test-id)"} {
+		if !strings.Contains(logged, s) {
+			t.Errorf("%s expected %q in log entry, returned %s", t.Name(), s, logged)
+		}
+	}
+}
+
+func TestRecoverPanic_ErrAbortHandler(t *testing.T) {
+	var buf bytes.Buffer
+	srv := New(&Opts{})
+	srv.logger = log.New(&buf, "", 0)
+	srv.GET("/abort", func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+	w := httptest.NewRecorder()
+	func() {
+		defer func() {
+			if rcv := recover(); rcv != http.ErrAbortHandler {
+				t.Errorf("%s expected %v re-panicked, returned %v", t.Name(), http.ErrAbortHandler, rcv)
+			}
+		}()
+		srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/abort", nil))
+	}()
+
+	if w.Code == http.StatusInternalServerError || w.Body.Len() != 0 {
+		t.Errorf("%s expected no 500 body, returned %d %s", t.Name(), w.Code, w.Body.String())
+	}
+	if buf.Len() != 0 {
+		t.Errorf("%s expected no error log, returned %s", t.Name(), buf.String())
+	}
+}
+
+func TestWithRecover(t *testing.T) {
+	var (
+		hookRequest   *http.Request
+		hookRecovered interface{}
+	)
+	srv := New(&Opts{})
+	srv.GET("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("route panic")
+	}, WithRecover(func(r *http.Request, recovered interface{}) {
+		hookRequest = r
+		hookRecovered = recovered
+	}))
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	if hookRecovered != "route panic" {
+		t.Errorf("%s expected %s, returned %v", t.Name(), "route panic", hookRecovered)
+	}
+	if hookRequest == nil || hookRequest.URL.Path != "/panic" {
+		t.Errorf("%s expected hook receive the request", t.Name())
+	}
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusInternalServerError, w.Code)
+	}
+}
+
+func TestGlobalOPTIONS(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GET("/items", okHandler)
+	srv.POST("/items", okHandler)
+	srv.GlobalOPTIONS(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Max-Age", "600")
+		ResponseStatus(w, http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/items", nil))
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("%s expected %d with Access-Control-Max-Age, returned %d %v", t.Name(), http.StatusNoContent, w.Code, w.Header())
+	}
+	if allow := w.Header().Get("Allow"); !strings.Contains(allow, http.MethodGet) || !strings.Contains(allow, http.MethodPost) {
+		t.Errorf("%s expected Allow of GET and POST, returned %s", t.Name(), allow)
+	}
+	if w.Header().Get("Request-Id") == "" {
+		t.Errorf("%s expected Header Request-Id not empty, found empty", t.Name())
+	}
+}
+
+func TestResetRoutes(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GET("/old", okHandler)
+	srv.GlobalOPTIONS(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Max-Age", "600")
+	})
+	srv.ResetRoutes()
+
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/old", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusNotFound, w.Code)
+	}
+	if routes := srv.Routes(); len(routes) != 0 {
+		t.Errorf("%s expected no routes, returned %v", t.Name(), routes)
+	}
+
+	// same path can be registered again.
+	srv.GET("/old", okHandler)
+	w = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/old", nil))
+	if w.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("%s expected GlobalOPTIONS kept, returned %v", t.Name(), w.Header())
+	}
+}
+
+func TestOnError(t *testing.T) {
+	var statuses []int
+	hook := OnError(func(r *http.Request, status int) {
+		statuses = append(statuses, status)
+	})
+	srv := New(&Opts{})
+	srv.GET("/error", func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusInternalServerError, "error")
+	}, hook)
+	srv.GET("/ok", func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusOK, "ok")
+	}, hook)
+
+	srv.handlers.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/error", nil))
+	if len(statuses) != 1 || statuses[0] != http.StatusInternalServerError {
+		t.Errorf("%s expected %v, returned %v", t.Name(), []int{http.StatusInternalServerError}, statuses)
+	}
+
+	statuses = nil
+	srv.handlers.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ok", nil))
+	if len(statuses) != 0 {
+		t.Errorf("%s expected hook not fired, returned %v", t.Name(), statuses)
+	}
+}
+
+func TestOnError_Panic(t *testing.T) {
+	var statuses []int
+	srv := New(&Opts{})
+	srv.logger = log.New(ioutil.Discard, "", 0)
+	srv.GET("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("test panic")
+	}, OnError(func(r *http.Request, status int) {
+		statuses = append(statuses, status)
+	}))
+
+	srv.handlers.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil))
+	if len(statuses) != 1 || statuses[0] != http.StatusInternalServerError {
+		t.Errorf("%s expected %v, returned %v", t.Name(), []int{http.StatusInternalServerError}, statuses)
+	}
+}
+
+func TestAfter(t *testing.T) {
+	var (
+		status int
+		size   int64
+	)
+	srv := New(&Opts{})
+	srv.GET("/created", func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusCreated, "created")
+	}, After(func(w ResponseInfo, r *http.Request) {
+		status, size = w.StatusCode(), w.BytesWritten()
+	}))
+
+	srv.handlers.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/created", nil))
+	if status != http.StatusCreated || size != int64(len("created")) {
+		t.Errorf("%s expected %d %d, returned %d %d", t.Name(), http.StatusCreated, len("created"), status, size)
+	}
+}
+
+type testPusher struct {
+	*httptest.ResponseRecorder
+	targets []string
+}
+
+func (p *testPusher) Push(target string, opts *http.PushOptions) error {
+	p.targets = append(p.targets, target)
+	return nil
+}
+
+func TestPush(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if err := Push(w, "/app.css", nil); err != nil {
+			t.Errorf("%s expected null error, found %v", t.Name(), err)
+		}
+		ResponseString(w, http.StatusOK, "page")
+	}
+	srv := New(&Opts{})
+	srv.GET("/page", handler, Timeout(time.Second))
+
+	p := &testPusher{ResponseRecorder: httptest.NewRecorder()}
+	srv.handlers.ServeHTTP(p, httptest.NewRequest(http.MethodGet, "/page", nil))
+	if len(p.targets) != 1 || p.targets[0] != "/app.css" {
+		t.Errorf("%s expected %v, returned %v", t.Name(), []string{"/app.css"}, p.targets)
+	}
+
+	var rw http.ResponseWriter = newResponseWriter(httptest.NewRecorder(), "", "")
+	if _, ok := rw.(http.Pusher); !ok {
+		t.Errorf("%s expected responseWriter implements http.Pusher", t.Name())
+	}
+	if err := Push(rw, "/app.css", nil); err != http.ErrNotSupported {
+		t.Errorf("%s expected %v, returned %v", t.Name(), http.ErrNotSupported, err)
+	}
+}
+
 func TestResponseHeader(t *testing.T) {
 	w := &httptest.ResponseRecorder{}
-	rw := &responseWriter{w, 200, "", ""}
+	rw := newResponseWriter(w, "", "")
 	responseHeader(rw, 200)
 }
 
+func TestResponseHeader_Date(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GET("/date", func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusOK, "ok")
+	})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/date")
+	if err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	resp.Body.Close()
+	dates := resp.Header.Values("Date")
+	if len(dates) != 1 {
+		t.Fatalf("%s expected %d Date header, returned %v", t.Name(), 1, dates)
+	}
+	if _, err := time.Parse(http.TimeFormat, dates[0]); err != nil {
+		t.Errorf("%s expected Date in http.TimeFormat, returned %s: %v", t.Name(), dates[0], err)
+	}
+}
+
 func TestResponse(t *testing.T) {
 	w := &httptest.ResponseRecorder{}
 	Response(w, 200, []byte("test"))
 }
 
+func TestResponseStatus(t *testing.T) {
+	w := httptest.NewRecorder()
+	rw := newResponseWriter(w, "test-id", "")
+	ResponseStatus(rw, http.StatusAccepted)
+	if w.Code != http.StatusAccepted || w.Body.Len() != 0 {
+		t.Errorf("%s expected %d with empty body, returned %d %s", t.Name(), http.StatusAccepted, w.Code, w.Body.String())
+	}
+	if w.Header().Get("Request-Id") != "test-id" {
+		t.Errorf("%s expected Header Request-Id %s, returned %s", t.Name(), "test-id", w.Header().Get("Request-Id"))
+	}
+}
+
+func TestResponseHead(t *testing.T) {
+	srv := New(&Opts{})
+	srv.HEAD("/file", func(w http.ResponseWriter, r *http.Request) {
+		ResponseHead(w, http.StatusOK, 1024)
+	})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	resp, err := http.Head(ts.URL + "/file")
+	if err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ContentLength != 1024 || len(body) != 0 {
+		t.Errorf("%s expected %d with Content-Length %d and no body, returned %d %d %d", t.Name(), http.StatusOK, 1024, resp.StatusCode, resp.ContentLength, len(body))
+	}
+	if resp.Header.Get("Request-Id") == "" {
+		t.Errorf("%s expected Header Request-Id not empty, found empty", t.Name())
+	}
+}
+
 func TestResponseJSON(t *testing.T) {
 	w := &httptest.ResponseRecorder{}
-	if err := ResponseJSON(w, 200, []byte("test")); err != nil {
+	if err := ResponseJSON(w, 200, []byte(`"test"`)); err != nil {
 		t.Errorf("%s expected null error, found not null", t.Name())
 	}
 }
 
+func TestResponseJSON_RawMessage(t *testing.T) {
+	w := httptest.NewRecorder()
+	rw := newResponseWriter(w, "", "")
+	raw := json.RawMessage(`{"a": [1, 2]}`)
+	if err := ResponseJSON(rw, 200, raw); err != nil {
+		t.Errorf("%s expected null error, found %v", t.Name(), err)
+	}
+	if w.Body.String() != string(raw) {
+		t.Errorf("%s expected %s, returned %s", t.Name(), raw, w.Body.String())
+	}
+}
+
+func TestResponseJSON_InvalidBytes(t *testing.T) {
+	w := httptest.NewRecorder()
+	rw := newResponseWriter(w, "", "")
+	if err := ResponseJSON(rw, 200, []byte("not json")); err != ErrInvalidJSON {
+		t.Errorf("%s expected %v, found %v", t.Name(), ErrInvalidJSON, err)
+	}
+	if w.Body.Len() != 0 || w.Header().Get("Content-Type") != "" {
+		t.Errorf("%s expected nothing written, returned %s", t.Name(), w.Body.String())
+	}
+}
+
+func TestResponseJSONWith_DisableHTMLEscape(t *testing.T) {
+	w := httptest.NewRecorder()
+	rw := newResponseWriter(w, "", "")
+	body := map[string]string{"html": "<b>a&b</b>"}
+	if err := ResponseJSONWith(rw, 200, body, &JSONOpts{DisableHTMLEscape: true}); err != nil {
+		t.Errorf("%s expected null error, found %v", t.Name(), err)
+	}
+	expected := `{"html":"<b>a&b</b>"}` + "\n"
+	if w.Body.String() != expected {
+		t.Errorf("%s expected %s, returned %s", t.Name(), expected, w.Body.String())
+	}
+}
+
+func TestResponseJSONWith_DefaultEscapeHTML(t *testing.T) {
+	w := httptest.NewRecorder()
+	rw := newResponseWriter(w, "", "")
+	body := map[string]string{"html": "<b>"}
+	if err := ResponseJSONWith(rw, 200, body, nil); err != nil {
+		t.Errorf("%s expected null error, found %v", t.Name(), err)
+	}
+	expected := `{"html":"\u003cb\u003e"}` + "\n"
+	if w.Body.String() != expected {
+		t.Errorf("%s expected %s, returned %s", t.Name(), expected, w.Body.String())
+	}
+}
+
+func TestResponseJSON_EncodeError(t *testing.T) {
+	w := httptest.NewRecorder()
+	rw := newResponseWriter(w, "", "")
+	if err := ResponseJSON(rw, http.StatusOK, map[string]interface{}{"ch": make(chan int)}); err == nil {
+		t.Fatalf("%s expected encoding error, found null", t.Name())
+	}
+	if w.Body.Len() != 0 || w.Header().Get("Content-Type") != "" {
+		t.Errorf("%s expected nothing written, returned %v %s", t.Name(), w.Header(), w.Body.String())
+	}
+	ResponseString(rw, http.StatusInternalServerError, "error")
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusInternalServerError, w.Code)
+	}
+}
+
+type failingJSONEncoder struct {
+	size int
+}
+
+func (e failingJSONEncoder) Encode(w io.Writer, v interface{}) error {
+	if _, err := w.Write(bytes.Repeat([]byte(" "), e.size)); err != nil {
+		return err
+	}
+	return errors.New("encode failed")
+}
+
+func TestResponseJSON_EncodeErrorMidStream(t *testing.T) {
+	w := httptest.NewRecorder()
+	rw := newResponseWriter(w, "", "")
+	err := ResponseJSONWith(rw, http.StatusOK, nil, &JSONOpts{Encoder: failingJSONEncoder{size: jsonBufferSize + 1}})
+	if err == nil || err.Error() != "encode failed" {
+		t.Errorf("%s expected encode failed, returned %v", t.Name(), err)
+	}
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" || w.Body.Len() != jsonBufferSize+1 {
+		t.Errorf("%s expected status and partial body already sent, returned %d %v %d", t.Name(), w.Code, w.Header(), w.Body.Len())
+	}
+}
+
+func TestResponseJSONStream(t *testing.T) {
+	items := make(chan interface{})
+	go func() {
+		defer close(items)
+		for i := 0; i < 1000; i++ {
+			items <- map[string]int{"id": i}
+		}
+	}()
+	w := httptest.NewRecorder()
+	if err := ResponseJSONStream(newResponseWriter(w, "", ""), http.StatusOK, items); err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	var decoded []map[string]int
+	if err := json.Unmarshal(w.Body.Bytes(), &decoded); err != nil {
+		t.Fatalf("%s expected valid json, found %v", t.Name(), err)
+	}
+	if len(decoded) != 1000 || decoded[999]["id"] != 999 {
+		t.Errorf("%s expected %d items in order, returned %d", t.Name(), 1000, len(decoded))
+	}
+	if !w.Flushed || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("%s expected flushed json response, returned %v %v", t.Name(), w.Flushed, w.Header())
+	}
+
+	empty := make(chan interface{})
+	close(empty)
+	w = httptest.NewRecorder()
+	ResponseJSONStream(newResponseWriter(w, "", ""), http.StatusOK, empty)
+	if w.Body.String() != "[]" {
+		t.Errorf("%s expected [], returned %s", t.Name(), w.Body.String())
+	}
+}
+
+func TestResponseNDJSON(t *testing.T) {
+	records := make(chan interface{})
+	go func() {
+		defer close(records)
+		for i := 0; i < 3; i++ {
+			records <- map[string]int{"id": i}
+		}
+	}()
+	w := httptest.NewRecorder()
+	if err := ResponseNDJSON(newResponseWriter(w, "", ""), http.StatusOK, records); err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	expected := "{\"id\":0}\n{\"id\":1}\n{\"id\":2}\n"
+	if w.Body.String() != expected {
+		t.Errorf("%s expected %q, returned %q", t.Name(), expected, w.Body.String())
+	}
+	if !w.Flushed || w.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Errorf("%s expected flushed ndjson response, returned %v %v", t.Name(), w.Flushed, w.Header())
+	}
+}
+
+type testJSONEncoder struct{}
+
+func (testJSONEncoder) Encode(w io.Writer, v interface{}) error {
+	_, err := w.Write([]byte("encoded"))
+	return err
+}
+
+func TestResponseJSONWith_Encoder(t *testing.T) {
+	w := httptest.NewRecorder()
+	rw := newResponseWriter(w, "", "")
+	if err := ResponseJSONWith(rw, 200, nil, &JSONOpts{Encoder: testJSONEncoder{}}); err != nil {
+		t.Errorf("%s expected null error, found %v", t.Name(), err)
+	}
+	if w.Body.String() != "encoded" {
+		t.Errorf("%s expected %s, returned %s", t.Name(), "encoded", w.Body.String())
+	}
+}
+
+func TestResponseJSONIndent(t *testing.T) {
+	w := httptest.NewRecorder()
+	rw := newResponseWriter(w, "", "")
+	body := map[string]int{"a": 1}
+	if err := ResponseJSONIndent(rw, 200, body, "", "  "); err != nil {
+		t.Errorf("%s expected null error, found %v", t.Name(), err)
+	}
+	expected := "{\n  \"a\": 1\n}\n"
+	if w.Body.String() != expected {
+		t.Errorf("%s expected %q, returned %q", t.Name(), expected, w.Body.String())
+	}
+}
+
 func TestResponseString(t *testing.T) {
 	w := &httptest.ResponseRecorder{}
 	ResponseString(w, 200, "string")
 }
 
+type testStringer struct{}
+
+func (testStringer) String() string { return "stringer" }
+
+func TestResponseString_Types(t *testing.T) {
+	tests := []struct {
+		body     interface{}
+		expected string
+	}{
+		{"string", "string"},
+		{[]byte("bytes"), "bytes"},
+		{errors.New("error"), "error"},
+		{testStringer{}, "stringer"},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("refused")}, "dial tcp: refused"},
+		{123, "123"},
+		{true, "true"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		ResponseString(newResponseWriter(w, "", ""), 200, test.body)
+		if w.Body.String() != test.expected {
+			t.Errorf("%s expected %s, returned %s", t.Name(), test.expected, w.Body.String())
+		}
+	}
+}
+
 var testSrv = newServer()
 
 func TestGET(t *testing.T) {
@@ -162,6 +960,110 @@ func TestOPTIONS(t *testing.T) {
 	testSrv.OPTIONS("/options", testHandler, TestMiddleware)
 }
 
+func TestMethod(t *testing.T) {
+	srv := New(&Opts{})
+	srv.Method("REPORT", "/calendar", func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusMultiStatus, r.Method)
+	})
+
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, httptest.NewRequest("REPORT", "/calendar", nil))
+	if w.Code != http.StatusMultiStatus || w.Body.String() != "REPORT" {
+		t.Errorf("%s expected %d %s, returned %d %s", t.Name(), http.StatusMultiStatus, "REPORT", w.Code, w.Body.String())
+	}
+}
+
+func TestRegister_Duplicate(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/x", "httpserver: route GET /x is already registered"},
+		{"/:id", "httpserver: failed to register route GET /:id: "},
+	}
+	srv := New(&Opts{})
+	srv.GET("/x", testHandler)
+	for _, test := range tests {
+		func() {
+			defer func() {
+				rcv, _ := recover().(string)
+				if !strings.HasPrefix(rcv, test.expected) {
+					t.Errorf("%s expected %s, returned %s", t.Name(), test.expected, rcv)
+				}
+			}()
+			srv.GET(test.path, testHandler)
+		}()
+	}
+}
+
+func TestMethodNotAllowed_Allow(t *testing.T) {
+	var allowSeen, idSeen string
+	srv := New(&Opts{
+		MethodNotAllowedHandler: func(w http.ResponseWriter, r *http.Request) {
+			allowSeen = w.Header().Get("Allow")
+			idSeen = r.Header.Get("Request-Id")
+			ResponseStatus(w, http.StatusMethodNotAllowed)
+		},
+	})
+	srv.GET("/items", testHandler)
+	srv.POST("/items", testHandler)
+
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/items", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusMethodNotAllowed, w.Code)
+	}
+	if allow := w.Header().Get("Allow"); allow != "GET, OPTIONS, POST" || allowSeen != allow {
+		t.Errorf("%s expected Allow %s seen by handler, returned %s, handler saw %s", t.Name(), "GET, OPTIONS, POST", allow, allowSeen)
+	}
+	if idSeen == "" {
+		t.Errorf("%s expected request id in custom handler", t.Name())
+	}
+
+	srv = New(&Opts{HeadForGet: true})
+	srv.GET("/items", testHandler)
+	w = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/items", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusMethodNotAllowed, w.Code)
+	}
+	if allow := w.Header().Get("Allow"); allow != "GET, HEAD, OPTIONS" {
+		t.Errorf("%s expected Allow %s, returned %s", t.Name(), "GET, HEAD, OPTIONS", allow)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GET("/users/:id", testHandler)
+
+	matched, params := srv.Lookup(http.MethodGet, "/users/7")
+	if !matched || params["id"] != "7" {
+		t.Errorf("%s expected matched with id=7, returned %v %v", t.Name(), matched, params)
+	}
+	if matched, _ := srv.Lookup(http.MethodPost, "/users/7"); matched {
+		t.Errorf("%s expected not matched", t.Name())
+	}
+	if matched, _ := srv.Lookup(http.MethodGet, "/orders/7"); matched {
+		t.Errorf("%s expected not matched", t.Name())
+	}
+}
+
+func TestRoute_Metadata(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GET("/users/:id", testHandler).WithSummary("Get user").WithTags("users")
+	srv.Group("/admin").DELETE("/users/:id", testHandler).WithTags("admin", "users")
+	srv.GET("/health", testHandler)
+
+	expected := []Route{
+		{Method: http.MethodGet, Path: "/users/:id", Summary: "Get user", Tags: []string{"users"}},
+		{Method: http.MethodDelete, Path: "/admin/users/:id", Tags: []string{"admin", "users"}},
+		{Method: http.MethodGet, Path: "/health"},
+	}
+	if routes := srv.Routes(); !reflect.DeepEqual(routes, expected) {
+		t.Errorf("%s expected %v, returned %v", t.Name(), expected, routes)
+	}
+}
+
 func TestFILES_OnSuccess(t *testing.T) {
 	testSrv.FILES("/test/*filepath", "/test/")
 	go testSrv.Run()
@@ -188,6 +1090,53 @@ func TestRenderHTML(t *testing.T) {
 	RenderHTML(tmplName, tmpl, nil, funcMap)
 }
 
+func TestRenderHTMLContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	data := map[string]interface{}{"title": "Test"}
+	html, err := RenderHTMLContext(ctx, "test", `<h1>{{ .title }}</h1>`, data)
+	if err != nil || html != "<h1>Test</h1>" {
+		t.Errorf("%s expected %s, returned %s %v", t.Name(), "<h1>Test</h1>", html, err)
+	}
+
+	// cancelled while executing, checked once execution finished.
+	funcMap := template.FuncMap{
+		"abort": func() string {
+			cancel()
+			return ""
+		},
+	}
+	if _, err := RenderHTMLContext(ctx, "test", `<h1>{{ abort }}</h1>`, nil, funcMap); err != context.Canceled {
+		t.Errorf("%s expected %v, returned %v", t.Name(), context.Canceled, err)
+	}
+	if _, err := RenderHTMLContext(ctx, "test", `<h1>Test</h1>`, nil); err != context.Canceled {
+		t.Errorf("%s expected %v, returned %v", t.Name(), context.Canceled, err)
+	}
+	tmplNameToTmpl := map[string]string{
+		"test":  `<h1>{{ template "test2" }}</h1>`,
+		"test2": "<h2>test</h2>",
+	}
+	if _, err := RenderMultiHTMLContext(ctx, "test", tmplNameToTmpl, nil); err != context.Canceled {
+		t.Errorf("%s expected %v, returned %v", t.Name(), context.Canceled, err)
+	}
+	if html, err := RenderMultiHTMLContext(context.Background(), "test", tmplNameToTmpl, nil); err != nil || html != "<h1><h2>test</h2></h1>" {
+		t.Errorf("%s expected %s, returned %s %v", t.Name(), "<h1><h2>test</h2></h1>", html, err)
+	}
+}
+
+func TestExecuteHTML(t *testing.T) {
+	tmplName := "test"
+	tmpl := `<h1>{{ .title }}</h1>`
+	data := map[string]interface{}{"title": "Test"}
+	var buff bytes.Buffer
+	if err := ExecuteHTML(&buff, tmplName, tmpl, data); err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	html, _ := RenderHTML(tmplName, tmpl, data)
+	if buff.String() != string(html) {
+		t.Errorf("%s expected %s, returned %s", t.Name(), html, buff.String())
+	}
+}
+
 func TestResponseHTML(t *testing.T) {
 	tmplName := "test"
 	tmpl := `<h1>Test</h1>`
@@ -200,6 +1149,43 @@ func TestResponseHTML(t *testing.T) {
 	ResponseHTML(w, tmplName, tmpl, nil, funcMap)
 }
 
+func TestResponseHTML_Error(t *testing.T) {
+	funcMap := template.FuncMap{
+		"fail": func() (string, error) {
+			return "", fmt.Errorf("fail")
+		},
+	}
+	tmpls := []string{
+		`<h1>{{ .title </h1>`,
+		`<h1>partial</h1>{{ fail }}`,
+	}
+	for _, tmpl := range tmpls {
+		w := httptest.NewRecorder()
+		rw := newResponseWriter(w, "", "")
+		if err := ResponseHTML(rw, "test", tmpl, nil, funcMap); err == nil {
+			t.Errorf("%s expected error, return null", t.Name())
+		}
+		if w.Body.Len() != 0 || w.Header().Get("Date") != "" {
+			t.Errorf("%s expected nothing written, returned %s", t.Name(), w.Body.String())
+		}
+	}
+}
+
+func TestResponseMultiHTML_Error(t *testing.T) {
+	w := httptest.NewRecorder()
+	rw := newResponseWriter(w, "", "")
+	tmplNameToTmpl := map[string]string{
+		"test":  `<h1>test</h1>{{ template "test2" }}`,
+		"test2": "<h2>{{ .x.y }</h2>",
+	}
+	if err := ResponseMultiHTML(rw, "test", tmplNameToTmpl, nil); err == nil {
+		t.Errorf("%s expected error, return null", t.Name())
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("%s expected nothing written, returned %s", t.Name(), w.Body.String())
+	}
+}
+
 func TestRenderMultiHTML(t *testing.T) {
 	tmplName := "test"
 	tmplNameToTmpl := map[string]string{
@@ -232,3 +1218,101 @@ func TestResponseMultiHTML(t *testing.T) {
 func TestLoadTemplate(t *testing.T) {
 	LoadTemplate("_")
 }
+
+func TestResponseServiceUnavailable(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GET("/upstream", func(w http.ResponseWriter, r *http.Request) {
+		ResponseServiceUnavailable(w, 1500*time.Millisecond)
+	})
+	r := httptest.NewRequest(http.MethodGet, "/upstream", nil)
+	r.Header.Set("Request-Id", "test-id")
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, r)
+
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "2" {
+		t.Errorf("%s expected %d with Retry-After %s, returned %d with %s", t.Name(), http.StatusServiceUnavailable, "2", w.Code, w.Header().Get("Retry-After"))
+	}
+	expected := `{"error":"service unavailable","retry_after":2}` + "\n"
+	if w.Body.String() != expected || w.Header().Get("Request-Id") != "test-id" {
+		t.Errorf("%s expected %s with Request-Id, returned %s %v", t.Name(), expected, w.Body.String(), w.Header())
+	}
+}
+
+func TestResponseJSON_ContentLength(t *testing.T) {
+	large := make([]string, jsonBufferSize)
+	srv := New(&Opts{})
+	srv.GET("/small", func(w http.ResponseWriter, r *http.Request) {
+		ResponseJSON(w, http.StatusOK, map[string]string{"a": "b"})
+	})
+	srv.GET("/raw", func(w http.ResponseWriter, r *http.Request) {
+		ResponseJSON(w, http.StatusOK, json.RawMessage(`{"a":"b"}`))
+	})
+	srv.GET("/large", func(w http.ResponseWriter, r *http.Request) {
+		ResponseJSON(w, http.StatusOK, large)
+	})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	encoded, _ := json.Marshal(large)
+	// large body is beyond jsonBufferSize, still sent with Content-Length as encoding/json encodes it whole anyway.
+	tests := map[string]int64{"/small": 10, "/raw": 9, "/large": int64(len(encoded) + 1)}
+	for path, expected := range tests {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("%s expected null error, found %v", t.Name(), err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.ContentLength != expected {
+			t.Errorf("%s %s expected Content-Length %d, returned %d", t.Name(), path, expected, resp.ContentLength)
+		}
+		if expected > 0 && int64(len(body)) != expected {
+			t.Errorf("%s %s expected body of %d bytes, returned %d", t.Name(), path, expected, len(body))
+		}
+	}
+}
+
+func TestResponseJSON_PooledOpts(t *testing.T) {
+	body := map[string]string{"a": "<b>"}
+	w := httptest.NewRecorder()
+	ResponseJSONWith(newResponseWriter(w, "", ""), http.StatusOK, body, &JSONOpts{Indent: "  ", DisableHTMLEscape: true})
+	if expected := "{\n  \"a\": \"<b>\"\n}\n"; w.Body.String() != expected {
+		t.Errorf("%s expected %s, returned %s", t.Name(), expected, w.Body.String())
+	}
+	// pooled encoder must not keep options of previous response.
+	w = httptest.NewRecorder()
+	ResponseJSON(newResponseWriter(w, "", ""), http.StatusOK, body)
+	if expected := `{"a":"\u003cb\u003e"}` + "\n"; w.Body.String() != expected {
+		t.Errorf("%s expected %s, returned %s", t.Name(), expected, w.Body.String())
+	}
+}
+
+// discardResponseWriter http.ResponseWriter discarding the response, for benchmarks.
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (d *discardResponseWriter) Header() http.Header         { return d.header }
+func (d *discardResponseWriter) WriteHeader(statusCode int)  {}
+func (d *discardResponseWriter) Write(p []byte) (int, error) { return len(p), nil }
+
+func benchmarkResponseJSON(b *testing.B, body interface{}) {
+	w := &discardResponseWriter{header: make(http.Header)}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ResponseJSON(newResponseWriter(w, "", ""), http.StatusOK, body)
+	}
+}
+
+func BenchmarkResponseJSON_Small(b *testing.B) {
+	benchmarkResponseJSON(b, map[string]interface{}{"id": 1, "name": "test", "active": true})
+}
+
+func BenchmarkResponseJSON_Large(b *testing.B) {
+	items := make([]string, 10000)
+	for i := range items {
+		items[i] = "item"
+	}
+	benchmarkResponseJSON(b, items)
+}
@@ -0,0 +1,224 @@
+package httpserver
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// IdempotencyStore store of Idempotency middleware.
+// Implement this to share keys between instances, e.g. Redis with SET NX for Lock.
+type IdempotencyStore interface {
+	// Get return stored response of key, false if not found, expired or still in progress.
+	Get(key string) (*CachedResponse, bool)
+
+	// Lock reserve key for ttl while its first request is in progress.
+	// Return false if key is already reserved or has stored response.
+	Lock(key string, ttl time.Duration) bool
+
+	// Set store response of key for ttl, replacing its reservation.
+	Set(key string, res *CachedResponse, ttl time.Duration)
+
+	// Unlock release reservation of key without storing response, so the request can be retried.
+	Unlock(key string)
+}
+
+// defaultIdempotencyTTL TTL of Idempotency if not set.
+const defaultIdempotencyTTL = 24 * time.Hour
+
+// defaultIdempotencyLockTTL LockTTL of Idempotency if not set.
+const defaultIdempotencyLockTTL = time.Minute
+
+// defaultIdempotencyMaxEntries maximum number of keys kept by NewMemoryIdempotencyStore.
+const defaultIdempotencyMaxEntries = 10000
+
+// idempotencySweepInterval how often memory idempotency store removes all expired keys on write.
+const idempotencySweepInterval = time.Minute
+
+// IdempotencyOpts options for Idempotency middleware.
+type IdempotencyOpts struct {
+	// TTL how long a response is stored for its key. If empty then 24 hours.
+	TTL time.Duration
+
+	// LockTTL how long a key is reserved while its first request is in progress, so reservation of request whose
+	// instance crashed expires. Keep it longer than the slowest handler. If empty then 1 minute.
+	LockTTL time.Duration
+
+	// Store optional, if empty then in-memory store of up to 10000 keys is used.
+	Store IdempotencyStore
+
+	// Header optional, if empty then Idempotency-Key is used.
+	Header string
+
+	// Methods optional, if empty then only POST and PATCH are handled.
+	Methods []string
+}
+
+// Idempotency middleware to run handler once per idempotency key, so client can safely retry e.g. payment request.
+// Response of the first request is stored for opts.TTL and replayed for requests with the same key, method and path,
+// with header `Idempotent-Replayed: true`. Request with the same key arriving while the first is still in progress
+// is responded 409 Conflict, client retries it later to get the stored response.
+// 5xx response is not stored, so failed request can be retried. Request without the header is passed through.
+// If opts is nil then defaults are used.
+func Idempotency(opts *IdempotencyOpts) Middleware {
+	if opts == nil {
+		opts = &IdempotencyOpts{}
+	}
+	ttl := opts.TTL
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTL
+	}
+	lockTTL := opts.LockTTL
+	if lockTTL <= 0 {
+		lockTTL = defaultIdempotencyLockTTL
+	}
+	store := opts.Store
+	if store == nil {
+		store = NewMemoryIdempotencyStore()
+	}
+	header := opts.Header
+	if header == "" {
+		header = "Idempotency-Key"
+	}
+	methods := map[string]bool{http.MethodPost: true, http.MethodPatch: true}
+	if len(opts.Methods) > 0 {
+		methods = make(map[string]bool, len(opts.Methods))
+		for _, m := range opts.Methods {
+			methods[m] = true
+		}
+	}
+
+	return func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			idempotencyKey := r.Header.Get(header)
+			if idempotencyKey == "" || !methods[r.Method] {
+				next(w, r)
+				return
+			}
+			key := r.Method + " " + r.URL.Path + " " + idempotencyKey
+			if res, ok := store.Get(key); ok {
+				w.Header().Set("Idempotent-Replayed", "true")
+				res.writeTo(w)
+				return
+			}
+			if !store.Lock(key, lockTTL) {
+				if res, ok := store.Get(key); ok { // first request has just finished.
+					w.Header().Set("Idempotent-Replayed", "true")
+					res.writeTo(w)
+					return
+				}
+				ResponseJSON(w, http.StatusConflict, map[string]string{
+					"error":  "request with the same idempotency key is in progress",
+					"header": header,
+				})
+				return
+			}
+
+			stored := false
+			defer func() {
+				if !stored {
+					store.Unlock(key)
+				}
+			}()
+			rec := newRecorder(w)
+			next(rec, r)
+			res := rec.result()
+			if res.StatusCode < http.StatusInternalServerError {
+				store.Set(key, res, ttl)
+				stored = true
+			}
+			res.writeTo(w)
+		}
+	}
+}
+
+// NewMemoryIdempotencyStore return in-memory IdempotencyStore of up to 10000 keys, see NewMemoryIdempotencyStoreSize.
+func NewMemoryIdempotencyStore() IdempotencyStore {
+	return NewMemoryIdempotencyStoreSize(defaultIdempotencyMaxEntries)
+}
+
+// NewMemoryIdempotencyStoreSize return in-memory IdempotencyStore of up to maxEntries keys, so clients sending
+// distinct keys can not grow memory without bound. Expired keys are evicted on lookup and swept every minute on write.
+// Once the store is full, the key expiring soonest is evicted, so its request can run again if retried.
+func NewMemoryIdempotencyStoreSize(maxEntries int) IdempotencyStore {
+	if maxEntries <= 0 {
+		maxEntries = defaultIdempotencyMaxEntries
+	}
+	return &memoryIdempotencyStore{
+		items:      make(map[string]memoryCacheItem),
+		maxEntries: maxEntries,
+		sweptAt:    time.Now(),
+	}
+}
+
+// memoryIdempotencyStore keep reserved key as item with nil response.
+type memoryIdempotencyStore struct {
+	mu         sync.Mutex
+	items      map[string]memoryCacheItem
+	maxEntries int
+	// sweptAt last time all expired keys were removed.
+	sweptAt time.Time
+}
+
+func (m *memoryIdempotencyStore) Get(key string) (*CachedResponse, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.lookup(key)
+	if !ok || item.res == nil {
+		return nil, false
+	}
+	return item.res, true
+}
+
+func (m *memoryIdempotencyStore) Lock(key string, ttl time.Duration) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.lookup(key); ok {
+		return false
+	}
+	m.put(key, memoryCacheItem{expiredAt: time.Now().Add(ttl)})
+	return true
+}
+
+func (m *memoryIdempotencyStore) Set(key string, res *CachedResponse, ttl time.Duration) {
+	m.mu.Lock()
+	m.put(key, memoryCacheItem{res: res, expiredAt: time.Now().Add(ttl)})
+	m.mu.Unlock()
+}
+
+// put store item of key, sweeping expired keys if due and evicting if the store is full. m.mu must be held.
+func (m *memoryIdempotencyStore) put(key string, item memoryCacheItem) {
+	if now := time.Now(); now.Sub(m.sweptAt) >= idempotencySweepInterval {
+		m.sweptAt = now
+		for k, it := range m.items {
+			if now.After(it.expiredAt) {
+				delete(m.items, k)
+			}
+		}
+	}
+	if _, ok := m.items[key]; !ok && len(m.items) >= m.maxEntries {
+		evict(m.items, m.maxEntries)
+	}
+	m.items[key] = item
+}
+
+func (m *memoryIdempotencyStore) Unlock(key string) {
+	m.mu.Lock()
+	if item, ok := m.items[key]; ok && item.res == nil {
+		delete(m.items, key)
+	}
+	m.mu.Unlock()
+}
+
+// lookup return unexpired item of key, deleting it if expired. m.mu must be held.
+func (m *memoryIdempotencyStore) lookup(key string) (memoryCacheItem, bool) {
+	item, ok := m.items[key]
+	if !ok {
+		return item, false
+	}
+	if time.Now().After(item.expiredAt) {
+		delete(m.items, key)
+		return item, false
+	}
+	return item, true
+}
@@ -0,0 +1,156 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIdempotency(t *testing.T) {
+	calls := 0
+	srv := New(&Opts{})
+	srv.POST("/payments", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		ResponseString(w, http.StatusCreated, "paid")
+	}, Idempotency(&IdempotencyOpts{TTL: time.Minute}))
+
+	post := func(key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/payments", nil)
+		if key != "" {
+			r.Header.Set("Idempotency-Key", key)
+		}
+		w := httptest.NewRecorder()
+		srv.handlers.ServeHTTP(w, r)
+		return w
+	}
+
+	w1 := post("abc")
+	w2 := post("abc")
+	if calls != 1 {
+		t.Errorf("%s expected handler called %d time, returned %d", t.Name(), 1, calls)
+	}
+	if w2.Code != http.StatusCreated || w2.Body.String() != "paid" || w2.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("%s expected replayed response, returned %d %s %v", t.Name(), w2.Code, w2.Body.String(), w2.Header())
+	}
+	if w1.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("%s expected first response not replayed", t.Name())
+	}
+
+	post("xyz")
+	post("")
+	post("")
+	if calls != 4 {
+		t.Errorf("%s expected handler called %d times, returned %d", t.Name(), 4, calls)
+	}
+}
+
+func TestIdempotency_ServerError(t *testing.T) {
+	calls := 0
+	srv := New(&Opts{})
+	srv.POST("/payments", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		ResponseString(w, http.StatusBadGateway, "upstream down")
+	}, Idempotency(&IdempotencyOpts{TTL: time.Minute}))
+
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest(http.MethodPost, "/payments", nil)
+		r.Header.Set("Idempotency-Key", "abc")
+		srv.handlers.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	if calls != 2 {
+		t.Errorf("%s expected handler called %d times, returned %d", t.Name(), 2, calls)
+	}
+}
+
+func TestIdempotency_Concurrent(t *testing.T) {
+	testIdempotencyConcurrent(t, &IdempotencyOpts{TTL: time.Minute})
+}
+
+func TestIdempotency_ConcurrentDefaultTTL(t *testing.T) {
+	// empty TTL must neither expire the reservation nor the stored response at once.
+	testIdempotencyConcurrent(t, &IdempotencyOpts{TTL: 0})
+	testIdempotencyConcurrent(t, nil)
+}
+
+func testIdempotencyConcurrent(t *testing.T, opts *IdempotencyOpts) {
+	var calls int32
+	release := make(chan struct{})
+	srv := New(&Opts{})
+	srv.POST("/payments", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		ResponseString(w, http.StatusCreated, "paid")
+	}, Idempotency(opts))
+
+	post := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/payments", nil)
+		r.Header.Set("Idempotency-Key", "abc")
+		w := httptest.NewRecorder()
+		srv.handlers.ServeHTTP(w, r)
+		return w
+	}
+
+	var (
+		wg    sync.WaitGroup
+		first *httptest.ResponseRecorder
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		first = post()
+	}()
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	w := post()
+	if w.Code != http.StatusConflict {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusConflict, w.Code)
+	}
+	close(release)
+	wg.Wait()
+	if first.Code != http.StatusCreated {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusCreated, first.Code)
+	}
+	w = post()
+	if w.Code != http.StatusCreated || w.Header().Get("Idempotent-Replayed") != "true" || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("%s expected replayed %d, returned %d after %d calls", t.Name(), http.StatusCreated, w.Code, calls)
+	}
+}
+
+func TestMemoryIdempotencyStore_MaxEntries(t *testing.T) {
+	store := NewMemoryIdempotencyStoreSize(2)
+	res := &CachedResponse{StatusCode: http.StatusCreated}
+	store.Set("a", res, time.Minute)
+	store.Set("b", res, time.Hour)
+	if !store.Lock("c", time.Hour) {
+		t.Fatalf("%s expected %s locked", t.Name(), "c")
+	}
+
+	if _, ok := store.Get("a"); ok {
+		t.Errorf("%s expected %s evicted", t.Name(), "a")
+	}
+	if _, ok := store.Get("b"); !ok {
+		t.Errorf("%s expected %s kept", t.Name(), "b")
+	}
+	if n := len(store.(*memoryIdempotencyStore).items); n != 2 {
+		t.Errorf("%s expected %d entries, returned %d", t.Name(), 2, n)
+	}
+}
+
+func TestMemoryIdempotencyStore_Sweep(t *testing.T) {
+	store := NewMemoryIdempotencyStore().(*memoryIdempotencyStore)
+	res := &CachedResponse{StatusCode: http.StatusCreated}
+	store.Set("a", res, time.Millisecond)
+	store.Lock("b", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	store.sweptAt = time.Now().Add(-idempotencySweepInterval)
+	store.Set("c", res, time.Hour)
+	if n := len(store.items); n != 1 {
+		t.Errorf("%s expected expired keys swept leaving %d entry, returned %d", t.Name(), 1, n)
+	}
+}
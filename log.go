@@ -2,43 +2,123 @@ package httpserver
 
 import (
 	"bufio"
+	"errors"
+	"io"
+	"log"
 	"net/http"
 	"os"
+	"sync/atomic"
 	"time"
 )
 
+// errBufferFull returned by buffer Write if log is dropped.
+var errBufferFull = errors.New("httpserver: log buffer is full")
+
 // buffer memory to store log before writing them into writer/file
 type buffer chan []byte
 
 // Write overwrite io.Writer Write method to instead of writing directly into file,
 // it passes the bytes into buffer memory to be written into actual writer/file asynchronously.
+// It never blocks, p is dropped if buffer is full so logging does not stall request handling.
 func (b buffer) Write(p []byte) (int, error) {
-	b <- append(([]byte)(nil), p...)
-	return len(p), nil
+	select {
+	case b <- append(([]byte)(nil), p...):
+		return len(p), nil
+	default:
+		return 0, errBufferFull
+	}
+}
+
+// droppedCounter count logs dropped by the buffer, exposed as Stats.DroppedLogs.
+type droppedCounter struct {
+	buffer  buffer
+	dropped *uint64
+}
+
+func (dc droppedCounter) Write(p []byte) (int, error) {
+	n, err := dc.buffer.Write(p)
+	if err != nil {
+		atomic.AddUint64(dc.dropped, 1)
+	}
+	return n, err
+}
+
+// logBufferSize number of log entries held by buffer before further logs are dropped. Kept small as every
+// async writer allocates it up front and holds the pending entries, e.g. about 1MiB of 256 byte log lines.
+const logBufferSize = 4096
+
+// asyncLogger return logger writing asynchronously into stderr through buffer.
+func (s *Server) asyncLogger() *log.Logger {
+	return log.New(s.asyncWriter(os.Stderr), "", 0)
+}
+
+// asyncWriter return writer passing logs into w asynchronously through buffer, so slow w, e.g. file, never blocks request.
+func (s *Server) asyncWriter(w io.Writer) io.Writer {
+	b := make(buffer, logBufferSize)
+	go writeTo(b, w)
+	return droppedCounter{buffer: b, dropped: &s.stats.droppedLogs}
 }
 
-// worker to write log data from buffer memory into writer/file asynchronously.
-func write(b buffer) {
-	writer := bufio.NewWriter(os.Stderr)
+// writeTo write log data from buffer memory into w asynchronously.
+func writeTo(b buffer, w io.Writer) {
+	writer := bufio.NewWriter(w)
 	for p := range b {
 		writer.Write(p)
 		writer.Flush()
 	}
 }
 
+// NoLog middleware to exclude the route from access log, e.g. health check or metrics endpoints.
+func NoLog() Middleware {
+	return func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if rw, ok := lookupResponseWriter(w); ok {
+				rw.noLog = true
+			}
+			next(w, r)
+		}
+	}
+}
+
+// accessLog return logger of access log, the server logger unless AccessLogWriter is set.
+func (s *Server) accessLog() *log.Logger {
+	if s.accessLogger != nil {
+		return s.accessLogger
+	}
+	return s.logger
+}
+
 // middleware for log
 func (s *Server) log(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		next(w, r)
 		elapsed := time.Since(start)
-		var statusCode int
-		rw, ok := w.(*responseWriter)
+		var (
+			statusCode   int
+			bytesWritten int64
+			writeErr     error
+		)
+		rw, ok := lookupResponseWriter(w)
+		if ok && rw.noLog {
+			return
+		}
 		if !ok { // impossible...!!! but let be safe.
 			statusCode = http.StatusOK // default http.ResponseWriter status code
 		} else {
 			statusCode = rw.statusCode
+			bytesWritten = rw.bytesWritten
+			writeErr = rw.writeErr
+		}
+		// route pattern groups requests of the same route, concrete path follows it.
+		route := RoutePattern(r)
+		if route == "" {
+			route = r.URL.Path
+		}
+		if writeErr != nil {
+			s.accessLog().Printf("%s | httpserver | %s | %d | %s | %s | %v | %s | %dB | write error: %v\n", time.Now().Format(time.RFC3339), r.Method, statusCode, route, r.URL.Path, elapsed, r.Header.Get("Request-Id"), bytesWritten, writeErr)
+			return
 		}
-		s.logger.Printf("%s | httpserver | %s | %d | %s | %v | %s\n", time.Now().Format(time.RFC3339), r.Method, statusCode, r.URL.Path, elapsed, r.Header.Get("Request-Id"))
+		s.accessLog().Printf("%s | httpserver | %s | %d | %s | %s | %v | %s | %dB\n", time.Now().Format(time.RFC3339), r.Method, statusCode, route, r.URL.Path, elapsed, r.Header.Get("Request-Id"), bytesWritten)
 	}
 }
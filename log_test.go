@@ -1,9 +1,15 @@
 package httpserver
 
 import (
+	"bytes"
+	"log"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func TestWrite(t *testing.T) {
@@ -14,11 +20,52 @@ func TestWrite(t *testing.T) {
 	}
 }
 
+func TestWrite_BufferFull(t *testing.T) {
+	w := make(buffer, 1)
+	if _, err := w.Write([]byte("first")); err != nil {
+		t.Errorf("%s expected null error, found %v", t.Name(), err)
+	}
+	done := make(chan error)
+	go func() {
+		_, err := w.Write([]byte("second"))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != errBufferFull {
+			t.Errorf("%s expected %v, returned %v", t.Name(), errBufferFull, err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("%s expected write not blocked on full buffer", t.Name())
+	}
+}
+
+func TestDroppedLogs(t *testing.T) {
+	s := New(&Opts{})
+	s.logger = log.New(droppedCounter{buffer: make(buffer, 1), dropped: &s.stats.droppedLogs}, "", 0)
+	for i := 0; i < 3; i++ {
+		s.logger.Printf("line %d", i)
+	}
+	if dropped := s.Stats().DroppedLogs; dropped != 2 {
+		t.Errorf("%s expected %d, returned %d", t.Name(), 2, dropped)
+	}
+}
+
+func BenchmarkWrite_Saturated(b *testing.B) {
+	w := make(buffer, 1)
+	w <- []byte("fill")
+	p := []byte("log line")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.Write(p)
+	}
+}
+
 func TestWriter(t *testing.T) {
 	w := make(buffer, 1)
 	b := []byte("test")
 	w <- b
-	go write(w)
+	go writeTo(w, os.Stderr)
 }
 
 func TestLog(t *testing.T) {
@@ -35,3 +82,103 @@ func TestLog(t *testing.T) {
 	rw := newResponseWriter(w, "", "")
 	h(rw, r)
 }
+
+// brokenWriter simulate connection closed by client after limit bytes are written.
+type brokenWriter struct {
+	*httptest.ResponseRecorder
+	limit int
+}
+
+func (bw *brokenWriter) Write(p []byte) (int, error) {
+	if len(p) > bw.limit {
+		n, _ := bw.ResponseRecorder.Write(p[:bw.limit])
+		bw.limit = 0
+		return n, syscall.EPIPE
+	}
+	bw.limit -= len(p)
+	return bw.ResponseRecorder.Write(p)
+}
+
+func TestLog_WriteError(t *testing.T) {
+	var buf bytes.Buffer
+	s := newServer()
+	s.logger = log.New(&buf, "", 0)
+	h := s.log(func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusOK, "large body")
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/large", nil)
+	h(newResponseWriter(&brokenWriter{ResponseRecorder: httptest.NewRecorder(), limit: 5}, "", ""), r)
+	if logged := buf.String(); !strings.Contains(logged, "| 5B | write error: "+syscall.EPIPE.Error()) {
+		t.Errorf("%s expected write error logged, returned %s", t.Name(), logged)
+	}
+
+	buf.Reset()
+	h(newResponseWriter(httptest.NewRecorder(), "", ""), r)
+	if logged := buf.String(); !strings.Contains(logged, "| 10B\n") || strings.Contains(logged, "write error") {
+		t.Errorf("%s expected bytes logged without error, returned %s", t.Name(), logged)
+	}
+}
+
+func TestNoLog(t *testing.T) {
+	var buf bytes.Buffer
+	s := New(&Opts{})
+	s.logger = log.New(&buf, "", 0)
+	s.Use(s.log)
+	s.GET("/health", func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusOK, "ok")
+	}, NoLog())
+	s.GET("/users", func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusOK, "users")
+	})
+
+	s.handlers.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	s.handlers.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users", nil))
+
+	logged := buf.String()
+	if strings.Contains(logged, "/health") {
+		t.Errorf("%s expected /health not logged, returned %s", t.Name(), logged)
+	}
+	if !strings.Contains(logged, "/users") {
+		t.Errorf("%s expected /users logged, returned %s", t.Name(), logged)
+	}
+}
+
+func TestAccessLogWriter_ErrorLogWriter(t *testing.T) {
+	var access, errs bytes.Buffer
+	s := New(&Opts{EnableLogger: true, AccessLogWriter: &access, ErrorLogWriter: &errs})
+	s.GET("/ok", func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusOK, "ok")
+	})
+	s.GET("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("test panic")
+	})
+
+	s.handlers.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ok", nil))
+	s.handlers.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	if !strings.Contains(access.String(), "| 200 | /ok |") || strings.Contains(access.String(), "PANIC") {
+		t.Errorf("%s expected only access lines in access log, returned %s", t.Name(), access.String())
+	}
+	if !strings.Contains(errs.String(), "PANIC") || strings.Contains(errs.String(), "/ok") {
+		t.Errorf("%s expected only error lines in error log, returned %s", t.Name(), errs.String())
+	}
+}
+
+func TestLog_RoutePattern(t *testing.T) {
+	var access bytes.Buffer
+	var route string
+	s := New(&Opts{EnableLogger: true, AccessLogWriter: &access})
+	s.GET("/users/:id", func(w http.ResponseWriter, r *http.Request) {
+		route = RoutePattern(r)
+		ResponseString(w, http.StatusOK, "ok")
+	})
+	s.handlers.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/7", nil))
+
+	if route != "/users/:id" {
+		t.Errorf("%s expected %s, returned %s", t.Name(), "/users/:id", route)
+	}
+	if !strings.Contains(access.String(), "| 200 | /users/:id | /users/7 |") {
+		t.Errorf("%s expected route pattern and path logged, returned %s", t.Name(), access.String())
+	}
+}
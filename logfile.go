@@ -0,0 +1,158 @@
+package httpserver
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultLogFileMaxSize maximum size in megabytes of log file before it is rotated if LogFileConfig.MaxSize is empty.
+const defaultLogFileMaxSize = 100
+
+// backupTimeFormat timestamp of rotated log file name, e.g. server-2006-01-02T15-04-05.000.log.
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// LogFileConfig log file written along with the other log destinations, rotated once it reaches MaxSize.
+// Rotated file is renamed by its rotation time, e.g. /var/log/server-2006-01-02T15-04-05.000.log,
+// and new file is created at Path.
+type LogFileConfig struct {
+	// Path of the log file, its directory must exist.
+	Path string
+
+	// MaxSize maximum size in megabytes of the log file before it is rotated. If empty then 100 megabytes.
+	MaxSize int
+
+	// MaxBackups maximum number of rotated files to keep. If empty then all are kept, subject to MaxAge.
+	MaxBackups int
+
+	// MaxAge maximum days to keep rotated files. If empty then they are not removed by age.
+	MaxAge int
+}
+
+// withLogFile write logs, both access and the others, into log file of config too,
+// asynchronously so rotation and slow disk do not block request.
+func (s *Server) withLogFile(config *LogFileConfig) {
+	file := s.asyncWriter(newRotatingFile(config))
+	if s.accessLogger != nil {
+		s.accessLogger = log.New(teeWriter{s.accessLogger.Writer(), file}, "", 0)
+	}
+	s.logger = log.New(teeWriter{s.logger.Writer(), file}, "", 0)
+}
+
+// teeWriter write into all writers, unlike io.MultiWriter it carries on if one fails,
+// e.g. log dropped by full buffer of stderr is still written into log file.
+type teeWriter []io.Writer
+
+func (t teeWriter) Write(p []byte) (int, error) {
+	var err error
+	for _, w := range t {
+		if _, werr := w.Write(p); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return len(p), err
+}
+
+// rotatingFile io.Writer into file rotated by size. File is opened on first write.
+type rotatingFile struct {
+	mu     sync.Mutex
+	config LogFileConfig
+	file   *os.File
+	size   int64
+}
+
+func newRotatingFile(config *LogFileConfig) *rotatingFile {
+	rf := &rotatingFile{config: *config}
+	if rf.config.MaxSize <= 0 {
+		rf.config.MaxSize = defaultLogFileMaxSize
+	}
+	return rf
+}
+
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.file == nil {
+		if err := rf.open(); err != nil {
+			return 0, err
+		}
+	}
+	if rf.size+int64(len(p)) > int64(rf.config.MaxSize)<<20 && rf.size > 0 {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// open open existing log file for appending, or create it.
+func (rf *rotatingFile) open() error {
+	f, err := os.OpenFile(rf.config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	rf.file = f
+	rf.size = info.Size()
+	return nil
+}
+
+// rotate rename current file into backup, create new one and remove backups beyond MaxBackups and MaxAge.
+func (rf *rotatingFile) rotate() error {
+	if err := rf.file.Close(); err != nil {
+		return err
+	}
+	rf.file = nil
+	if err := os.Rename(rf.config.Path, rf.backupName(time.Now())); err != nil {
+		return err
+	}
+	if err := rf.open(); err != nil {
+		return err
+	}
+	rf.prune()
+	return nil
+}
+
+// backupName return name of file rotated at t.
+func (rf *rotatingFile) backupName(t time.Time) string {
+	ext := filepath.Ext(rf.config.Path)
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(rf.config.Path, ext), t.Format(backupTimeFormat), ext)
+}
+
+// prune remove old backups, best effort.
+func (rf *rotatingFile) prune() {
+	if rf.config.MaxBackups <= 0 && rf.config.MaxAge <= 0 {
+		return
+	}
+	ext := filepath.Ext(rf.config.Path)
+	backups, err := filepath.Glob(strings.TrimSuffix(rf.config.Path, ext) + "-*" + ext)
+	if err != nil {
+		return
+	}
+	// newest first, timestamp in name sorts chronologically.
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	cutoff := time.Now().Add(-time.Duration(rf.config.MaxAge) * 24 * time.Hour)
+	for i, backup := range backups {
+		if rf.config.MaxBackups > 0 && i >= rf.config.MaxBackups {
+			os.Remove(backup)
+			continue
+		}
+		if rf.config.MaxAge > 0 {
+			if info, err := os.Stat(backup); err == nil && info.ModTime().Before(cutoff) {
+				os.Remove(backup)
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package httpserver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogFile_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "server.log")
+	srv := New(&Opts{
+		ErrorLogWriter: ioutil.Discard,
+		LogFile:        &LogFileConfig{Path: path, MaxSize: 1, MaxBackups: 1},
+	})
+	line := strings.Repeat("a", 1<<10)
+	// enough to rotate twice, only one backup is kept.
+	for i := 0; i < 2100; i++ {
+		srv.logger.Println(line)
+	}
+
+	srv.logger.Println("done")
+	// written asynchronously, wait until the last line reaches the file.
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if b, _ := ioutil.ReadFile(path); strings.HasSuffix(string(b), "done\n") {
+			break
+		}
+	}
+
+	backups, _ := filepath.Glob(filepath.Join(dir, "server-*.log"))
+	if len(backups) != 1 {
+		t.Errorf("%s expected %d backup, returned %v", t.Name(), 1, backups)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	if info.Size() == 0 || info.Size() > 1<<20 {
+		t.Errorf("%s expected log file within max size, returned %d", t.Name(), info.Size())
+	}
+}
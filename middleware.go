@@ -2,65 +2,55 @@ package httpserver
 
 import (
 	"net/http"
+	"reflect"
+	"runtime"
 )
 
+// Use add server middlewares, applied to routes registered after it.
+// Middlewares of a route are chained in this order, outermost first:
+// server middlewares, group middlewares, then route middlewares, each in the order they were given.
 func (s *Server) Use(m ...Middleware) {
 	for _, v := range m {
 		s.middlewares = append(s.middlewares, v)
 	}
 }
 
+// middlewareChain return server middlewares followed by middlewares, outermost first.
+func (s *Server) middlewareChain(middlewares []Middleware) []Middleware {
+	chain := make([]Middleware, 0, len(s.middlewares)+len(middlewares))
+	chain = append(chain, s.middlewares...)
+	return append(chain, middlewares...)
+}
+
 // chainMiddlewares chain all middlewares to handler
 func (s *Server) chainMiddlewares(handler http.HandlerFunc, middlewares ...Middleware) http.HandlerFunc {
-	h := handler
-	var ch http.HandlerFunc
-
-	if len(middlewares) > 0 {
-		lM := len(middlewares) - 1
-		for i := lM; i >= 0; i-- {
-			ch = middlewares[i](h)
-			h = ch
-		}
-	}
-
-	if len(s.middlewares) > 0 {
-		lS := len(s.middlewares) - 1
-		for i := lS; i >= 0; i-- {
-			ch = s.middlewares[i](h)
-			h = ch
-		}
-	}
-
-	return h
+	return Chain(handler, s.middlewareChain(middlewares)...)
 }
 
 func (g *Group) chainMiddlewares(handler http.HandlerFunc, middlewares ...Middleware) http.HandlerFunc {
-	h := handler
-	var ch http.HandlerFunc
+	return g.server.chainMiddlewares(handler, g.withMiddlewares(middlewares)...)
+}
 
-	if len(middlewares) > 0 {
-		lM := len(middlewares) - 1
-		for i := lM; i >= 0; i-- {
-			ch = middlewares[i](h)
-			h = ch
-		}
+// Chain wrap handler with middlewares so the first one runs first (outermost) and the last one runs right before handler,
+// the same order as middlewares of routes are applied. Use it to build chains outside the router, e.g. for http.Handler of other mux.
+func Chain(handler http.HandlerFunc, middlewares ...Middleware) http.HandlerFunc {
+	h := handler
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
 	}
+	return h
+}
 
-	if len(g.middlewares) > 0 {
-		lG := len(g.middlewares) - 1
-		for i := lG; i >= 0; i-- {
-			ch = g.middlewares[i](h)
-			h = ch
-		}
+// Middlewares return function names of middlewares chained to the route, outermost first, for debugging.
+// Nil if route is not registered.
+func (s *Server) Middlewares(method string, path string) []string {
+	middlewares, ok := s.routeMiddlewares[method+" "+path]
+	if !ok {
+		return nil
 	}
-
-	if len(g.server.middlewares) > 0 {
-		lS := len(g.server.middlewares) - 1
-		for i := lS; i >= 0; i-- {
-			ch = g.server.middlewares[i](h)
-			h = ch
-		}
+	names := make([]string, len(middlewares))
+	for i, m := range middlewares {
+		names[i] = runtime.FuncForPC(reflect.ValueOf(m).Pointer()).Name()
 	}
-
-	return h
+	return names
 }
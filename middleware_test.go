@@ -2,6 +2,9 @@ package httpserver
 
 import (
 	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -28,3 +31,118 @@ func TestGroupChainMiddlewares(t *testing.T) {
 	grp := srv.Group("/test", TestMiddleware)
 	grp.chainMiddlewares(handler, TestMiddleware)
 }
+
+func TestMiddlewareReuse(t *testing.T) {
+	auth := func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				ResponseString(w, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+			w.Header().Set("X-Path", r.URL.Path)
+			next(w, r)
+		}
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusOK, r.URL.Path)
+	}
+	srv := New(&Opts{})
+	grp := srv.Group("/group", auth)
+	paths := []string{"/a", "/b", "/c", "/group/d", "/group/e"}
+	srv.GET(paths[0], handler, auth)
+	srv.GET(paths[1], handler, auth)
+	srv.POST(paths[2], handler, auth)
+	grp.GET("/d", handler)
+	grp.PUT("/e", handler)
+
+	methods := []string{http.MethodGet, http.MethodGet, http.MethodPost, http.MethodGet, http.MethodPut}
+	for i, p := range paths {
+		r := httptest.NewRequest(methods[i], p, nil)
+		r.Header.Set("Authorization", "token")
+		w := httptest.NewRecorder()
+		srv.handlers.ServeHTTP(w, r)
+		if w.Code != http.StatusOK || w.Body.String() != p || w.Header().Get("X-Path") != p {
+			t.Errorf("%s expected %d %s, returned %d %s", t.Name(), http.StatusOK, p, w.Code, w.Body.String())
+		}
+
+		r = httptest.NewRequest(methods[i], p, nil)
+		w = httptest.NewRecorder()
+		srv.handlers.ServeHTTP(w, r)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusUnauthorized, w.Code)
+		}
+	}
+}
+
+var executionOrder []string
+
+func globalMiddleware(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		executionOrder = append(executionOrder, "global")
+		next(w, r)
+	}
+}
+
+func groupMiddleware(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		executionOrder = append(executionOrder, "group")
+		next(w, r)
+	}
+}
+
+func routeMiddleware(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		executionOrder = append(executionOrder, "route")
+		next(w, r)
+	}
+}
+
+func TestMiddlewaresOrder(t *testing.T) {
+	executionOrder = nil
+	srv := New(&Opts{})
+	srv.Use(globalMiddleware)
+	srv.Group("/v1", groupMiddleware).GET("/order", func(w http.ResponseWriter, r *http.Request) {
+		executionOrder = append(executionOrder, "handler")
+	}, routeMiddleware)
+
+	srv.handlers.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/order", nil))
+	expected := []string{"global", "group", "route", "handler"}
+	if !reflect.DeepEqual(executionOrder, expected) {
+		t.Errorf("%s expected %v, returned %v", t.Name(), expected, executionOrder)
+	}
+
+	names := srv.Middlewares(http.MethodGet, "/v1/order")
+	suffixes := []string{".globalMiddleware", ".groupMiddleware", ".routeMiddleware"}
+	if len(names) != len(suffixes) {
+		t.Fatalf("%s expected %d middlewares, returned %v", t.Name(), len(suffixes), names)
+	}
+	for i := range suffixes {
+		if !strings.HasSuffix(names[i], suffixes[i]) {
+			t.Errorf("%s expected %s, returned %s", t.Name(), suffixes[i], names[i])
+		}
+	}
+	if names := srv.Middlewares(http.MethodGet, "/unknown"); names != nil {
+		t.Errorf("%s expected nil, returned %v", t.Name(), names)
+	}
+}
+
+func TestChain(t *testing.T) {
+	var order []string
+	record := func(name string) Middleware {
+		return func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next(w, r)
+			}
+		}
+	}
+	h := Chain(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}, record("first"), record("second"), record("third"))
+	h(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	expected := []string{"first", "second", "third", "handler"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("%s expected %v, returned %v", t.Name(), expected, order)
+	}
+}
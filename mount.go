@@ -0,0 +1,44 @@
+package httpserver
+
+import (
+	"net/http"
+	"strings"
+)
+
+// mountParam name of catch-all param of mounted handler routes, removed from query before delegating.
+const mountParam = "httpserver_mount"
+
+// mountMethods methods routed into mounted handler.
+var mountMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodOptions, http.MethodConnect, http.MethodTrace,
+}
+
+// Mount serve prefix and every path under it, for all methods, by handler, e.g. third party GraphQL server or http.FileServer,
+// wrapped in the same middlewares chain, panic recovery and request id as other routes.
+// Prefix is stripped before delegating, so handler at /gql sees /gql/query as /query, and /gql itself as /.
+// Prefix must not end with slash and no other route may be registered under it.
+func (s *Server) Mount(prefix string, handler http.Handler, middlewares ...Middleware) {
+	h := mountHandler(prefix, handler)
+	for _, method := range mountMethods {
+		s.register(method, prefix, h, middlewares...)
+		s.register(method, prefix+"/*"+mountParam, h, middlewares...)
+	}
+}
+
+// mountHandler strip prefix from request path before passing it into handler.
+func mountHandler(prefix string, handler http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if _, ok := q[mountParam]; ok {
+			q.Del(mountParam)
+			r.URL.RawQuery = q.Encode()
+		}
+		r.URL.Path = strings.TrimPrefix(r.URL.Path, prefix)
+		if r.URL.Path == "" {
+			r.URL.Path = "/"
+		}
+		r.URL.RawPath = ""
+		handler.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,41 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMount(t *testing.T) {
+	called := false
+	tag := func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			next(w, r)
+		}
+	}
+	gql := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Request-Id") == "" {
+			t.Errorf("%s expected request id applied", t.Name())
+		}
+		w.Write([]byte(r.Method + " " + r.URL.Path + "?" + r.URL.RawQuery))
+	})
+	srv := New(&Opts{})
+	srv.Mount("/gql", gql, tag)
+
+	tests := map[string]string{
+		"/gql/anything?q=1": "POST /anything?q=1",
+		"/gql":              "POST /?",
+	}
+	for path, expected := range tests {
+		called = false
+		w := httptest.NewRecorder()
+		srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+		if w.Body.String() != expected {
+			t.Errorf("%s %s expected %s, returned %s", t.Name(), path, expected, w.Body.String())
+		}
+		if !called {
+			t.Errorf("%s %s expected middleware applied", t.Name(), path)
+		}
+	}
+}
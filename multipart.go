@@ -0,0 +1,18 @@
+package httpserver
+
+import (
+	"net/http"
+)
+
+// defaultMaxMultipartMemory used if MaxMultipartMemory is not set, same as http.Request.FormFile.
+const defaultMaxMultipartMemory = 32 << 20
+
+// ParseMultipartForm parse multipart form of r keeping at most MaxMultipartMemory of the server in memory,
+// the rest of file parts spills into temporary files. Form is then read from r.MultipartForm, r.FormValue or r.FormFile.
+func ParseMultipartForm(r *http.Request) error {
+	maxMemory := int64(defaultMaxMultipartMemory)
+	if s, ok := serverFromContext(r.Context()); ok && s.maxMultipartMemory > 0 {
+		maxMemory = s.maxMultipartMemory
+	}
+	return r.ParseMultipartForm(maxMemory)
+}
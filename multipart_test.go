@@ -0,0 +1,49 @@
+package httpserver
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestParseMultipartForm(t *testing.T) {
+	var (
+		parseErr error
+		onDisk   bool
+		size     int64
+	)
+	srv := New(&Opts{MaxMultipartMemory: 100})
+	srv.POST("/upload", func(w http.ResponseWriter, r *http.Request) {
+		if parseErr = ParseMultipartForm(r); parseErr != nil {
+			return
+		}
+		fh := r.MultipartForm.File["file"][0]
+		f, err := fh.Open()
+		if err != nil {
+			parseErr = err
+			return
+		}
+		defer f.Close()
+		_, onDisk = f.(*os.File)
+		size = fh.Size
+	})
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("file", "large.bin")
+	fw.Write(bytes.Repeat([]byte("x"), 1024))
+	mw.Close()
+	r := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	srv.handlers.ServeHTTP(httptest.NewRecorder(), r)
+
+	if parseErr != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), parseErr)
+	}
+	if !onDisk || size != 1024 {
+		t.Errorf("%s expected file of %d bytes spilled to disk, returned on disk %v size %d", t.Name(), 1024, onDisk, size)
+	}
+}
@@ -0,0 +1,93 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// openAPIVersion version of OpenAPI specification generated by OpenAPISpec.
+const openAPIVersion = "3.0.3"
+
+type openAPIDoc struct {
+	OpenAPI string                                 `json:"openapi"`
+	Info    openAPIInfo                            `json:"info"`
+	Paths   map[string]map[string]openAPIOperation `json:"paths"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIOperation struct {
+	Summary    string                     `json:"summary,omitempty"`
+	Tags       []string                   `json:"tags,omitempty"`
+	Parameters []openAPIParameter         `json:"parameters,omitempty"`
+	Responses  map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name     string            `json:"name"`
+	In       string            `json:"in"`
+	Required bool              `json:"required"`
+	Schema   map[string]string `json:"schema"`
+}
+
+type openAPIResponse struct {
+	Description string `json:"description"`
+}
+
+// openAPIMethods methods allowed as operations by OpenAPI, routes of other methods are left out.
+var openAPIMethods = map[string]bool{
+	"get": true, "put": true, "post": true, "delete": true,
+	"options": true, "head": true, "patch": true, "trace": true,
+}
+
+// OpenAPISpec generate skeleton OpenAPI 3 document in json from registered routes, with their summary, tags
+// and path params, e.g. /users/:id becomes /users/{id} with required id path parameter.
+// Request and response schemas are not inferred, fill them in from the generated document.
+func (s *Server) OpenAPISpec() ([]byte, error) {
+	doc := openAPIDoc{
+		OpenAPI: openAPIVersion,
+		Info:    openAPIInfo{Title: "httpserver", Version: "1.0.0"},
+		Paths:   make(map[string]map[string]openAPIOperation),
+	}
+	for _, route := range s.routes {
+		method := strings.ToLower(route.Method)
+		if !openAPIMethods[method] {
+			continue
+		}
+		path, params := openAPIPath(route.Path)
+		op := openAPIOperation{
+			Summary:   route.Summary,
+			Tags:      route.Tags,
+			Responses: map[string]openAPIResponse{"default": {Description: "default response"}},
+		}
+		for _, param := range params {
+			op.Parameters = append(op.Parameters, openAPIParameter{
+				Name:     param,
+				In:       "path",
+				Required: true,
+				Schema:   map[string]string{"type": "string"},
+			})
+		}
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]openAPIOperation)
+		}
+		doc.Paths[path][method] = op
+	}
+	return json.Marshal(doc)
+}
+
+// openAPIPath convert httprouter path into OpenAPI path template, returning its param names.
+func openAPIPath(path string) (string, []string) {
+	var params []string
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if len(segment) > 1 && (segment[0] == ':' || segment[0] == '*') {
+			params = append(params, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
@@ -0,0 +1,57 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GET("/users/:id", testHandler).WithSummary("Get user").WithTags("users")
+	srv.DELETE("/users/:id", testHandler)
+	srv.GET("/static/*filepath", testHandler)
+	srv.Method("PROPFIND", "/dav", testHandler)
+
+	spec, err := srv.OpenAPISpec()
+	if err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		t.Fatalf("%s expected valid json, found %v", t.Name(), err)
+	}
+	if doc["openapi"] != openAPIVersion {
+		t.Errorf("%s expected %s, returned %v", t.Name(), openAPIVersion, doc["openapi"])
+	}
+
+	paths := doc["paths"].(map[string]interface{})
+	var names []string
+	for name := range paths {
+		names = append(names, name)
+	}
+	if len(paths) != 2 || paths["/users/{id}"] == nil || paths["/static/{filepath}"] == nil {
+		t.Fatalf("%s expected paths /users/{id} and /static/{filepath}, returned %v", t.Name(), names)
+	}
+
+	user := paths["/users/{id}"].(map[string]interface{})
+	if user["delete"] == nil {
+		t.Errorf("%s expected delete operation", t.Name())
+	}
+	get := user["get"].(map[string]interface{})
+	if get["summary"] != "Get user" || !reflect.DeepEqual(get["tags"], []interface{}{"users"}) {
+		t.Errorf("%s expected summary and tags, returned %v", t.Name(), get)
+	}
+	expectedParams := []interface{}{map[string]interface{}{
+		"name":     "id",
+		"in":       "path",
+		"required": true,
+		"schema":   map[string]interface{}{"type": "string"},
+	}}
+	if !reflect.DeepEqual(get["parameters"], expectedParams) {
+		t.Errorf("%s expected %v, returned %v", t.Name(), expectedParams, get["parameters"])
+	}
+	if get["responses"] == nil {
+		t.Errorf("%s expected responses", t.Name())
+	}
+}
@@ -0,0 +1,74 @@
+package httpserver
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// recorder buffer the response written by handler so it can be replayed later, possibly more than once.
+type recorder struct {
+	w           http.ResponseWriter
+	header      http.Header
+	statusCode  int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func newRecorder(w http.ResponseWriter) *recorder {
+	return &recorder{w: w, header: make(http.Header), statusCode: http.StatusOK}
+}
+
+func (rec *recorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *recorder) WriteHeader(statusCode int) {
+	if rec.wroteHeader {
+		return
+	}
+	rec.wroteHeader = true
+	rec.statusCode = statusCode
+}
+
+func (rec *recorder) Write(p []byte) (int, error) {
+	rec.wroteHeader = true
+	return rec.body.Write(p)
+}
+
+// Unwrap return the wrapped http.ResponseWriter.
+func (rec *recorder) Unwrap() http.ResponseWriter {
+	return rec.w
+}
+
+// result return snapshot of the recorded response.
+func (rec *recorder) result() *CachedResponse {
+	return &CachedResponse{
+		StatusCode: rec.statusCode,
+		Header:     rec.header.Clone(),
+		Body:       append([]byte(nil), rec.body.Bytes()...),
+	}
+}
+
+// CachedResponse response recorded from a handler to be replayed later, e.g. by Cache middleware.
+type CachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// writeTo replay the response into w.
+// Request id headers are kept as w's own since the response may be recorded from other request,
+// and recorded Date header is dropped to let net/http set the current one.
+func (cr *CachedResponse) writeTo(w http.ResponseWriter) {
+	dst := w.Header()
+	for k, v := range cr.Header {
+		dst[k] = append([]string(nil), v...)
+	}
+	dst.Del("Date")
+	if rw, ok := lookupResponseWriter(w); ok {
+		dst.Set("Request-Id", rw.requestID)
+		dst.Set("X-Request-Id", rw.xRequestID)
+	}
+	w.WriteHeader(cr.StatusCode)
+	w.Write(cr.Body)
+}
@@ -0,0 +1,56 @@
+package httpserver
+
+import (
+	"net/http"
+
+	_router "github.com/julienschmidt/httprouter"
+)
+
+// Registrar register routes, implemented by Server and Group.
+type Registrar interface {
+	GET(path string, handler http.HandlerFunc, middlewares ...Middleware) *Route
+	HEAD(path string, handler http.HandlerFunc, middlewares ...Middleware) *Route
+	POST(path string, handler http.HandlerFunc, middlewares ...Middleware) *Route
+	PUT(path string, handler http.HandlerFunc, middlewares ...Middleware) *Route
+	DELETE(path string, handler http.HandlerFunc, middlewares ...Middleware) *Route
+	PATCH(path string, handler http.HandlerFunc, middlewares ...Middleware) *Route
+	OPTIONS(path string, handler http.HandlerFunc, middlewares ...Middleware) *Route
+	Method(method string, path string, handler http.HandlerFunc, middlewares ...Middleware) *Route
+	FILES(filePath string, rootPath string, middlewares ...Middleware) *Route
+	FILE(urlPath string, filePath string, middlewares ...Middleware) *Route
+	Group(prefix string, middlewares ...Middleware) *Group
+	HostGroup(host string, middlewares ...Middleware) *Group
+}
+
+// routing routers served together, stored into Server.routing.
+type routing struct {
+	handlers    *_router.Router
+	hostRouters map[string]*_router.Router
+}
+
+// UpdateRoutes replace all routes of running server with routes registered by fn, without restarting it
+// nor dropping connections, e.g. to apply new gateway configuration. Routes of HostGroup are replaced too,
+// register them again in fn via r.HostGroup to keep them.
+// New routes are built aside into new routers, in-flight and new requests keep being served by the old routers,
+// which are never modified, until they are swapped atomically once fn returns.
+// Server configuration, middlewares and router options are kept.
+// If fn panics, e.g. on conflicting routes, the old routes are kept and the panic is propagated.
+// Updates are serialized, but Routes, Lookup, Middlewares and OpenAPISpec must not be called concurrently with it.
+func (s *Server) UpdateRoutes(fn func(r Registrar)) {
+	s.routingMu.Lock()
+	defer s.routingMu.Unlock()
+
+	handlers, hostRouters, routes, routeMiddlewares := s.handlers, s.hostRouters, s.routes, s.routeMiddlewares
+	updated := false
+	defer func() {
+		if !updated {
+			s.handlers, s.hostRouters, s.routes, s.routeMiddlewares = handlers, hostRouters, routes, routeMiddlewares
+		}
+	}()
+	// fn registers into new routers, unseen by router until they are stored.
+	s.ResetRoutes()
+	fn(s)
+	next := &routing{handlers: s.handlers, hostRouters: s.hostRouters}
+	s.routing.Store(next)
+	updated = true
+}
@@ -0,0 +1,170 @@
+package httpserver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestUpdateRoutes(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GET("/v1", okHandler)
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	get := func(path string) int {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Errorf("%s expected null error, found %v", t.Name(), err)
+			return 0
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// keep serving while routes are updated, every request is served by either old or new routes.
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if v1, v2 := get("/v1"), get("/api/v2"); v1 != http.StatusOK && v2 != http.StatusOK {
+					t.Errorf("%s expected either route served, returned %d %d", t.Name(), v1, v2)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		srv.UpdateRoutes(func(r Registrar) {
+			r.Group("/api").GET("/v2", okHandler)
+		})
+	}
+	close(stop)
+	wg.Wait()
+
+	if code := get("/api/v2"); code != http.StatusOK {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusOK, code)
+	}
+	if code := get("/v1"); code != http.StatusNotFound {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusNotFound, code)
+	}
+	if routes := srv.Routes(); len(routes) != 1 || routes[0].Path != "/api/v2" {
+		t.Errorf("%s expected only /api/v2, returned %v", t.Name(), routes)
+	}
+}
+
+func TestUpdateRoutes_Panic(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GET("/v1", okHandler)
+	h := srv.Handler()
+
+	func() {
+		defer func() {
+			if rcv := recover(); rcv == nil {
+				t.Errorf("%s expected panic on conflicting routes", t.Name())
+			}
+		}()
+		srv.UpdateRoutes(func(r Registrar) {
+			r.GET("/v2", okHandler)
+			r.GET("/v2", okHandler)
+		})
+	}()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1", nil))
+	if w.Code != http.StatusOK || len(srv.Routes()) != 1 {
+		t.Errorf("%s expected old routes kept, returned %d %v", t.Name(), w.Code, srv.Routes())
+	}
+}
+
+func TestUpdateRoutes_HostGroup(t *testing.T) {
+	srv := New(&Opts{NotFoundHandler: func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusNotFound, "custom")
+	}})
+	srv.HostGroup("api.example.com").GET("/v1", okHandler)
+	h := srv.Handler()
+	old := srv.routing.Load().(*routing)
+
+	srv.UpdateRoutes(func(r Registrar) {
+		r.HostGroup("api.example.com").GET("/v2", okHandler)
+		r.Group("/web").HostGroup("app.example.com").GET("/home", okHandler)
+		r.GET("/home", okHandler)
+	})
+
+	tests := []struct {
+		target string
+		code   int
+		body   string
+	}{
+		{"http://api.example.com/v2", http.StatusOK, "ok"},
+		{"http://api.example.com/v1", http.StatusNotFound, "custom"},
+		{"http://app.example.com/web/home", http.StatusOK, "ok"},
+		{"http://other.example.com/home", http.StatusOK, "ok"},
+		{"http://other.example.com/v2", http.StatusNotFound, "custom"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if w.Code != tt.code || w.Body.String() != tt.body {
+			t.Errorf("%s %s expected %d %s, returned %d %s", t.Name(), tt.target, tt.code, tt.body, w.Code, w.Body.String())
+		}
+	}
+
+	// routers served before the update are left untouched.
+	if handle, _, _ := old.hostRouters["api.example.com"].Lookup(http.MethodGet, "/v1"); handle == nil {
+		t.Errorf("%s expected old host router kept /v1", t.Name())
+	}
+	if handle, _, _ := old.hostRouters["api.example.com"].Lookup(http.MethodGet, "/v2"); handle != nil {
+		t.Errorf("%s expected old host router without /v2", t.Name())
+	}
+}
+
+// TestUpdateRoutes_Race serve requests in process while routes are updated, run with -race to catch routers
+// read while being replaced.
+func TestUpdateRoutes_Race(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GET("/v1", okHandler)
+	srv.HostGroup("api.example.com").GET("/v1", okHandler)
+	h := srv.Handler()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, target := range []string{"/v1", "http://api.example.com/v1", "http://api.example.com/missing"} {
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+				if w.Code != http.StatusOK && w.Code != http.StatusNotFound {
+					t.Errorf("%s %s expected %d or %d, returned %d", t.Name(), target, http.StatusOK, http.StatusNotFound, w.Code)
+					return
+				}
+			}
+		}(target)
+	}
+	for i := 0; i < 1000; i++ {
+		srv.UpdateRoutes(func(r Registrar) {
+			r.GET("/v1", okHandler)
+			r.HostGroup("api.example.com").GET("/v1", okHandler)
+		})
+	}
+	close(stop)
+	wg.Wait()
+}
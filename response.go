@@ -2,18 +2,25 @@ package httpserver
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 )
 
 func responseHeader(w http.ResponseWriter, statusCode int) {
-	w.Header().Set("Date", time.Now().Format(time.RFC1123))
-	rw, ok := w.(*responseWriter)
+	if w.Header().Get("Date") == "" { // net/http does not add its own Date if set, so exactly one is sent.
+		w.Header().Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+	rw, ok := lookupResponseWriter(w)
 	if !ok {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -30,21 +37,307 @@ func Response(w http.ResponseWriter, statusCode int, body []byte) {
 	w.Write(body)
 }
 
+// ResponseStatus response with status code only, without body.
+// Request id headers are still written.
+// Call at the end line of your handler.
+func ResponseStatus(w http.ResponseWriter, statusCode int) {
+	responseHeader(w, statusCode)
+}
+
+// ResponseHead response HEAD request with status code and Content-Length of the body GET would respond, without body.
+// Negative contentLength leaves Content-Length unset, e.g. if it is unknown.
+// Call at the end line of your handler.
+func ResponseHead(w http.ResponseWriter, statusCode int, contentLength int64) {
+	if contentLength >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(contentLength, 10))
+	}
+	responseHeader(w, statusCode)
+}
+
 // ResponseJSON response by writing body with json encoder into http.ResponseWriter.
 // Body must be either struct or map[string]interface{}. Otherwise would result in incorrect parsing at client side.
-// If you have []byte as response body, then use Response function instead.
+// Body of []byte or json.RawMessage is treated as pre-encoded json and written as is,
+// ErrInvalidJSON is returned without writing anything if it is not a valid json.
+// Body is encoded in memory as whole before anything is written, encoding/json does not stream, so encoding error
+// is returned without writing anything, leaving the handler free to respond e.g. with 500, and body is sent with Content-Length.
+// Use ResponseJSONStream or ResponseNDJSON for large collections.
 // Call at the end line of your handler.
 func ResponseJSON(w http.ResponseWriter, statusCode int, body interface{}) error {
+	return ResponseJSONWith(w, statusCode, body, nil)
+}
+
+// ErrInvalidJSON returned by ResponseJSON if body is []byte or json.RawMessage which is not a valid json.
+var ErrInvalidJSON = errors.New("httpserver: body is not a valid json")
+
+// JSONEncoder encode v as json into w.
+// Implement this to plug other json library e.g. json-iterator.
+type JSONEncoder interface {
+	Encode(w io.Writer, v interface{}) error
+}
+
+// JSONOpts options to configure json encoding in ResponseJSONWith.
+type JSONOpts struct {
+	// DisableHTMLEscape disable escaping of <, > and & inside json string.
+	DisableHTMLEscape bool
+
+	// Prefix and Indent to indent the json output, useful for debugging.
+	Prefix string
+	Indent string
+
+	// Encoder optional, if set then other options are ignored.
+	Encoder JSONEncoder
+}
+
+// ResponseJSONWith same as ResponseJSON but with configurable json encoder.
+// If opts is nil then it behaves the same as ResponseJSON.
+// Custom opts.Encoder may write progressively, so only its first 32KB is buffered to still return early encoding error,
+// larger body is streamed without Content-Length once it exceeds that, and error after that can not change the status
+// already sent, client gets truncated body.
+// Call at the end line of your handler.
+func ResponseJSONWith(w http.ResponseWriter, statusCode int, body interface{}, opts *JSONOpts) error {
+	var raw []byte
+	switch b := body.(type) {
+	case []byte:
+		raw = b
+	case json.RawMessage:
+		raw = b
+	}
+	if raw != nil && !json.Valid(raw) {
+		return ErrInvalidJSON
+	}
+
+	if raw != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(raw)))
+		responseHeader(w, statusCode)
+		_, err := w.Write(raw)
+		return err
+	}
+	jw := newJSONWriter(w, statusCode)
+	defer jw.release()
+	if opts == nil || opts.Encoder == nil {
+		// encoding/json writes the whole body at once, buffering it all costs nothing more than the encoding itself.
+		jw.limit = -1
+	}
+	if err := jw.encode(body, opts); err != nil {
+		return err
+	}
+	if jw.streaming {
+		return nil
+	}
+	// whole body is buffered, its length is known so it is sent without chunked encoding.
+	w.Header().Set("Content-Length", strconv.Itoa(jw.buf.Len()))
+	return jw.flush()
+}
+
+// jsonBufferSize maximum size of json buffered from custom JSONEncoder before it starts streaming, bounding memory
+// of encoder writing progressively. Pooled buffer grown beyond it is not kept.
+const jsonBufferSize = 32 << 10
+
+// jsonWriter buffer encoded json up to limit, or all of it if limit is negative, before writing headers,
+// so encoding error can still be responded properly, then stream the rest into w.
+type jsonWriter struct {
+	w          http.ResponseWriter
+	statusCode int
+	limit      int
+	buf        bytes.Buffer
+	streaming  bool
+	// enc encoder writing into the jsonWriter itself, reused along with buf.
+	enc *json.Encoder
+}
+
+// jsonWriterPool reuse jsonWriter with its buffer and encoder, so small responses are encoded without allocating them per call.
+var jsonWriterPool = sync.Pool{
+	New: func() interface{} {
+		jw := &jsonWriter{}
+		jw.enc = json.NewEncoder(jw)
+		return jw
+	},
+}
+
+// newJSONWriter return pooled jsonWriter writing into w, call release once done.
+func newJSONWriter(w http.ResponseWriter, statusCode int) *jsonWriter {
+	jw := jsonWriterPool.Get().(*jsonWriter)
+	jw.w = w
+	jw.statusCode = statusCode
+	jw.limit = jsonBufferSize
+	jw.streaming = false
+	return jw
+}
+
+// release put jw back into the pool.
+func (jw *jsonWriter) release() {
+	jw.w = nil
+	jw.buf.Reset()
+	if jw.buf.Cap() > 2*jsonBufferSize { // grown by large body, do not keep it alive.
+		return
+	}
+	jsonWriterPool.Put(jw)
+}
+
+// encode body into jw with the pooled encoder, unless opts has its own Encoder.
+func (jw *jsonWriter) encode(body interface{}, opts *JSONOpts) error {
+	if opts == nil {
+		opts = &JSONOpts{}
+	}
+	if opts.Encoder != nil {
+		return opts.Encoder.Encode(jw, body)
+	}
+	jw.enc.SetEscapeHTML(!opts.DisableHTMLEscape)
+	jw.enc.SetIndent(opts.Prefix, opts.Indent)
+	return jw.enc.Encode(body)
+}
+
+func (jw *jsonWriter) Write(p []byte) (int, error) {
+	if jw.streaming {
+		return jw.w.Write(p)
+	}
+	if jw.limit < 0 || jw.buf.Len()+len(p) <= jw.limit {
+		return jw.buf.Write(p)
+	}
+	jw.streaming = true
+	if err := jw.flush(); err != nil {
+		return 0, err
+	}
+	return jw.w.Write(p)
+}
+
+// flush write headers and buffered json into w.
+func (jw *jsonWriter) flush() error {
+	jw.w.Header().Set("Content-Type", "application/json")
+	responseHeader(jw.w, jw.statusCode)
+	_, err := jw.w.Write(jw.buf.Bytes())
+	jw.buf.Reset()
+	return err
+}
+
+// jsonStreamFlushEvery number of items written by ResponseJSONStream between flushes.
+const jsonStreamFlushEvery = 100
+
+// ResponseJSONStream response json array by writing each item received from items as it arrives, until items is closed,
+// flushing periodically so client receives them without waiting for the whole array. Empty items is written as [].
+// Status is sent before the first item, error encoding or writing an item can not change it, client gets truncated array.
+// On error it returns without draining items, producer should stop, e.g. by watching r.Context().
+// Call at the end line of your handler.
+func ResponseJSONStream(w http.ResponseWriter, statusCode int, items <-chan interface{}) error {
 	w.Header().Set("Content-Type", "application/json")
 	responseHeader(w, statusCode)
-	return json.NewEncoder(w).Encode(body)
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	n := 0
+	for item := range items {
+		b, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+		n++
+		if n%jsonStreamFlushEvery == 0 {
+			flush(w)
+		}
+	}
+	if _, err := io.WriteString(w, "]"); err != nil {
+		return err
+	}
+	flush(w)
+	return nil
+}
+
+// ResponseNDJSON response newline delimited json, application/x-ndjson, by writing each item received from items
+// as one json line, flushed as soon as it is written, until items is closed.
+// Status is sent before the first item, error encoding or writing an item can not change it.
+// On error it returns without draining items, producer should stop, e.g. by watching r.Context().
+// Call at the end line of your handler.
+func ResponseNDJSON(w http.ResponseWriter, statusCode int, items <-chan interface{}) error {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	responseHeader(w, statusCode)
+	flush(w)
+	enc := json.NewEncoder(w)
+	for item := range items {
+		if err := enc.Encode(item); err != nil {
+			return err
+		}
+		flush(w)
+	}
+	return nil
+}
+
+// flush send buffered response of w to the client if w is http.Flusher.
+// Writers not implementing it are not unwrapped, as they may buffer the response, e.g. Cache, and must not be bypassed.
+func flush(w http.ResponseWriter) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// ResponseJSONIndent same as ResponseJSON but with indented json output, useful for debugging.
+// Keep using ResponseJSON in production to keep the response compact.
+// Call at the end line of your handler.
+func ResponseJSONIndent(w http.ResponseWriter, statusCode int, body interface{}, prefix, indent string) error {
+	return ResponseJSONWith(w, statusCode, body, &JSONOpts{Prefix: prefix, Indent: indent})
+}
+
+// Push initiate http/2 server push of target, e.g. critical css or js of rendered page, before responding.
+// Returns http.ErrNotSupported if connection is not http/2 or w does not support it, handler should carry on responding anyway.
+// Note that major browsers have dropped server push support, prefer preload links, e.g. `Link: </app.css>; rel=preload`,
+// or 103 Early Hints. It is kept for clients still honoring it.
+func Push(w http.ResponseWriter, target string, opts *http.PushOptions) error {
+	if rw, ok := lookupResponseWriter(w); ok {
+		return rw.Push(target, opts)
+	}
+	if pusher, ok := w.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+	return http.ErrNotSupported
 }
 
 // ResponseString response in form of string whatever passed into body param.
+// String and []byte are written as is, error by its Error() and fmt.Stringer by its String(),
+// other types are formatted with %v. Use ResponseJSON for struct instead of printing its Go syntax.
 // Call at the end line of your handler.
 func ResponseString(w http.ResponseWriter, statusCode int, body interface{}) {
 	responseHeader(w, statusCode)
-	fmt.Fprintf(w, "%v", body)
+	switch b := body.(type) {
+	case string:
+		io.WriteString(w, b)
+	case []byte:
+		w.Write(b)
+	case error:
+		io.WriteString(w, b.Error())
+	case fmt.Stringer:
+		io.WriteString(w, b.String())
+	default:
+		fmt.Fprintf(w, "%v", body)
+	}
+}
+
+// ResponseValidationError response 422 Unprocessable Entity with json body `{"errors":{field:message,...}}`,
+// e.g. ValidationErrors returned by BindAndValidate.
+// Call at the end line of your handler.
+func ResponseValidationError(w http.ResponseWriter, errs map[string]string) {
+	ResponseJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{"errors": errs})
+}
+
+// ResponseServiceUnavailable response 503 Service Unavailable with header `Retry-After` of retryAfter in seconds, rounded up,
+// and json body `{"error":"service unavailable","retry_after":seconds}`, e.g. when circuit breaker of upstream is open.
+// Retry-After is omitted if retryAfter is not positive.
+// Call at the end line of your handler.
+func ResponseServiceUnavailable(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int64((retryAfter + time.Second - 1) / time.Second)
+	body := map[string]interface{}{"error": "service unavailable"}
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+		body["retry_after"] = seconds
+	}
+	ResponseJSON(w, http.StatusServiceUnavailable, body)
 }
 
 // ResponseXML response by writing body with xml encoder into http.ResponseWriter.
@@ -58,6 +351,8 @@ func ResponseXML(w http.ResponseWriter, statusCode int, body interface{}) error
 }
 
 // ResponseHTML render and return html with given data.
+// Template is rendered fully before anything is written, so on parse/execute error nothing is written
+// and the error is returned, leaving the handler free to respond e.g. with 500.
 // @tmplName: template name if a template is wrapped inside {{ define "tmplName" }}, otherwise empty string.
 // @tmpl: template content in form of string loaded from template file.
 // @data: data to be embedded into html template, preferably in form of map[string]interface{}.
@@ -79,29 +374,54 @@ func ResponseHTML(w http.ResponseWriter, tmplName string, tmpl string, data inte
 // @data: data to be embedded into html template, preferably in form of map[string]interface{}.
 // @funcMap: golang template FuncMap.
 func RenderHTML(tmplName string, tmpl string, data interface{}, funcMap ...template.FuncMap) (template.HTML, error) {
-	var (
-		t    *template.Template
-		buff bytes.Buffer
-		err  error
-	)
-
-	t = template.New(tmplName)
-	for _, v := range funcMap {
-		t = t.Funcs(v)
+	var buff bytes.Buffer
+	if err := ExecuteHTML(&buff, tmplName, tmpl, data, funcMap...); err != nil {
+		return "", err
 	}
+	return template.HTML(buff.String()), nil
+}
 
-	t, err = t.Parse(tmpl)
+// RenderHTMLContext render template like RenderHTML, unless ctx is done, e.g. r.Context() of aborted request,
+// in which case ctx.Err() is returned. template.Execute can not be cancelled midway,
+// so ctx is checked only before parsing and after executing the template.
+func RenderHTMLContext(ctx context.Context, tmplName string, tmpl string, data interface{}, funcMap ...template.FuncMap) (template.HTML, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	html, err := RenderHTML(tmplName, tmpl, data, funcMap...)
 	if err != nil {
 		return "", err
 	}
-
-	if err = t.Execute(&buff, data); err != nil {
+	if err := ctx.Err(); err != nil {
 		return "", err
 	}
+	return html, nil
+}
 
-	return template.HTML(buff.String()), nil
+// ExecuteHTML render template with given data directly into w without rendering the whole page in memory first.
+// If execution failed midway, partial output may have been written into w.
+// @tmplName: template name if a template is wrapped inside {{ define "tmplName" }}, otherwise empty string.
+// @tmpl: template content in form of string loaded from template file.
+// @data: data to be embedded into html template, preferably in form of map[string]interface{}.
+// @funcMap: golang template FuncMap.
+func ExecuteHTML(w io.Writer, tmplName string, tmpl string, data interface{}, funcMap ...template.FuncMap) error {
+	t := template.New(tmplName)
+	for _, v := range funcMap {
+		t = t.Funcs(v)
+	}
+
+	t, err := t.Parse(tmpl)
+	if err != nil {
+		return err
+	}
+
+	return t.Execute(w, data)
 }
 
+// ResponseMultiHTML render and return html from multiple templates with given data.
+// Same as ResponseHTML, nothing is written on error.
+// @mainTmplName: name of the main template to be executed.
+// @tmplNameToTmpl: template name to template content, including the main template.
 func ResponseMultiHTML(w http.ResponseWriter, mainTmplName string, tmplNameToTmpl map[string]string, data interface{}, funcMap ...template.FuncMap) error {
 	html, err := RenderMultiHTML(mainTmplName, tmplNameToTmpl, data, funcMap...)
 	if err != nil {
@@ -112,12 +432,22 @@ func ResponseMultiHTML(w http.ResponseWriter, mainTmplName string, tmplNameToTmp
 }
 
 func RenderMultiHTML(mainTmplName string, tmplNameToTmpl map[string]string, data interface{}, funcMap ...template.FuncMap) (template.HTML, error) {
+	return RenderMultiHTMLContext(context.Background(), mainTmplName, tmplNameToTmpl, data, funcMap...)
+}
+
+// RenderMultiHTMLContext render multiple templates like RenderMultiHTML, unless ctx is done, e.g. r.Context() of aborted request,
+// in which case ctx.Err() is returned. template.Execute can not be cancelled midway,
+// so ctx is checked before parsing, between parsing each template and after executing the main template.
+func RenderMultiHTMLContext(ctx context.Context, mainTmplName string, tmplNameToTmpl map[string]string, data interface{}, funcMap ...template.FuncMap) (template.HTML, error) {
 	var (
 		t    *template.Template
 		buff bytes.Buffer
 		err  error
 	)
 
+	if err = ctx.Err(); err != nil {
+		return "", err
+	}
 	t = template.New(mainTmplName)
 	for _, v := range funcMap {
 		t = t.Funcs(v)
@@ -129,6 +459,9 @@ func RenderMultiHTML(mainTmplName string, tmplNameToTmpl map[string]string, data
 
 	for k, v := range tmplNameToTmpl {
 		if k != mainTmplName {
+			if err = ctx.Err(); err != nil {
+				return "", err
+			}
 			t, err = t.New(k).Parse(v)
 			if err != nil {
 				return "", err
@@ -139,6 +472,9 @@ func RenderMultiHTML(mainTmplName string, tmplNameToTmpl map[string]string, data
 	if err = t.ExecuteTemplate(&buff, mainTmplName, data); err != nil {
 		return "", err
 	}
+	if err = ctx.Err(); err != nil {
+		return "", err
+	}
 
 	return template.HTML(buff.String()), nil
 }
@@ -0,0 +1,40 @@
+//go:build go1.20
+// +build go1.20
+
+package httpserver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestResponseController is built only by go1.20 onwards, which added http.ResponseController,
+// since go.mod still supports go 1.15.
+func TestResponseController(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GET("/stream", func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		if err := rc.SetWriteDeadline(time.Now().Add(time.Second)); err != nil {
+			t.Errorf("%s expected null error setting write deadline, found %v", t.Name(), err)
+		}
+		responseHeader(w, http.StatusOK)
+		w.Write([]byte("chunk"))
+		if err := rc.Flush(); err != nil {
+			t.Errorf("%s expected null error flushing, found %v", t.Name(), err)
+		}
+	})
+	ts := srv.TestServer()
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/stream")
+	if err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "chunk" {
+		t.Errorf("%s expected %s, returned %s", t.Name(), "chunk", body)
+	}
+}
@@ -0,0 +1,57 @@
+//go:build linux
+// +build linux
+
+package httpserver
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestKeepAlivePeriod(t *testing.T) {
+	conns := make(chan net.Conn, 1)
+	srv := New(&Opts{
+		KeepAlivePeriod: 42 * time.Second,
+		ConnState: func(c net.Conn, cs http.ConnState) {
+			if cs == http.StateNew {
+				conns <- c
+			}
+		},
+	})
+	srv.GET("/ok", okHandler)
+	if err := srv.Start(); err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	defer srv.server.Close()
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/ok", srv.listener.Addr()))
+	if err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	resp.Body.Close()
+
+	raw, err := (<-conns).(*net.TCPConn).SyscallConn()
+	if err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	var (
+		keepAlive, idle int
+		sockErr         error
+	)
+	raw.Control(func(fd uintptr) {
+		if keepAlive, sockErr = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_KEEPALIVE); sockErr != nil {
+			return
+		}
+		idle, sockErr = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE)
+	})
+	if sockErr != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), sockErr)
+	}
+	if keepAlive != 1 || idle != 42 {
+		t.Errorf("%s expected keep-alive enabled with period %d, returned %d %d", t.Name(), 42, keepAlive, idle)
+	}
+}
@@ -0,0 +1,55 @@
+//go:build darwin || linux || freebsd || openbsd || netbsd
+// +build darwin linux freebsd openbsd netbsd
+
+package httpserver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestListener_InheritFD(t *testing.T) {
+	srv := New(&Opts{})
+	if _, err := srv.Listener(); err != ErrNotStarted {
+		t.Errorf("%s expected %v, returned %v", t.Name(), ErrNotStarted, err)
+	}
+	srv.GET("/whoami", func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusOK, "old")
+	})
+	if err := srv.Start(); err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	ln, err := srv.Listener()
+	if err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	addr := ln.Addr().String()
+	f, err := ln.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	defer f.Close()
+
+	next := New(&Opts{InheritFD: int(f.Fd())})
+	next.GET("/whoami", func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusOK, "new")
+	})
+	if err := next.Start(); err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	defer next.server.Close()
+	srv.server.Close()
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/whoami", addr))
+	if err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "new" {
+		t.Errorf("%s expected %s, returned %s", t.Name(), "new", body)
+	}
+}
@@ -0,0 +1,137 @@
+package httpserver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// ErrSlowConsumer returned by ResponseSSE with SSEDisconnect policy if client reads slower than events are produced
+// and the queue is full.
+var ErrSlowConsumer = errors.New("httpserver: sse client is too slow")
+
+// defaultSSEBufferSize capacity of queue of events not yet written by ResponseSSE if SSEOpts.BufferSize is empty.
+const defaultSSEBufferSize = 16
+
+// SSEEvent server-sent event written by ResponseSSE. Only Data is required.
+type SSEEvent struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+// SSEPolicy what ResponseSSE does if the queue of events is full because client reads slowly.
+type SSEPolicy int
+
+const (
+	// SSEDropOldest drop the oldest queued event to make room for the new one, client is notified with a comment line
+	// `: dropped n events` before the next written event.
+	SSEDropOldest SSEPolicy = iota
+	// SSEDisconnect stop responding, ResponseSSE returns ErrSlowConsumer and the connection is closed once handler returns.
+	SSEDisconnect
+)
+
+// SSEOpts options of ResponseSSE.
+type SSEOpts struct {
+	// BufferSize capacity of queue of events received but not yet written. If empty then 16.
+	BufferSize int
+
+	// Policy once the queue is full. Default SSEDropOldest.
+	Policy SSEPolicy
+}
+
+// ResponseSSE response server-sent events, text/event-stream, by writing each event received from events as it arrives,
+// flushed one by one, until events is closed and queued events are written, or client has gone away.
+// Events are queued up to opts.BufferSize while client is slow to read, then handled by opts.Policy, so memory
+// is bounded whatever client reading speed is. opts can be nil to use the defaults.
+// Producer must close events once r.Context() is done, queueing goroutine exits only when events is closed.
+// Call at the end line of your handler.
+func ResponseSSE(w http.ResponseWriter, r *http.Request, events <-chan SSEEvent, opts *SSEOpts) error {
+	if opts == nil {
+		opts = &SSEOpts{}
+	}
+	size := opts.BufferSize
+	if size <= 0 {
+		size = defaultSSEBufferSize
+	}
+	var (
+		queue    = make(chan SSEEvent, size)
+		overflow = make(chan struct{})
+		done     = make(chan struct{})
+		dropped  uint64
+	)
+	defer close(done)
+	go func() {
+		defer close(queue)
+		for ev := range events {
+			select {
+			case queue <- ev:
+				continue
+			case <-done:
+				continue // keep draining so producer is not blocked.
+			default:
+			}
+			if opts.Policy == SSEDisconnect {
+				close(overflow)
+				for range events {
+				}
+				return
+			}
+			// only this goroutine sends into queue, so there is room after dropping one.
+			select {
+			case <-queue:
+				atomic.AddUint64(&dropped, 1)
+			default:
+			}
+			queue <- ev
+		}
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	responseHeader(w, http.StatusOK)
+	flush(w)
+	for {
+		select {
+		case <-overflow:
+			return ErrSlowConsumer
+		default:
+		}
+		select {
+		case ev, ok := <-queue:
+			if !ok {
+				return nil
+			}
+			if _, err := w.Write(encodeSSE(ev, atomic.SwapUint64(&dropped, 0))); err != nil {
+				return err
+			}
+			flush(w)
+		case <-overflow:
+			return ErrSlowConsumer
+		case <-r.Context().Done():
+			return r.Context().Err()
+		}
+	}
+}
+
+// encodeSSE encode ev in event stream format, preceded by comment of dropped events if any.
+func encodeSSE(ev SSEEvent, dropped uint64) []byte {
+	var b bytes.Buffer
+	if dropped > 0 {
+		fmt.Fprintf(&b, ": dropped %d events\n\n", dropped)
+	}
+	if ev.ID != "" {
+		fmt.Fprintf(&b, "id: %s\n", ev.ID)
+	}
+	if ev.Event != "" {
+		fmt.Fprintf(&b, "event: %s\n", ev.Event)
+	}
+	for _, line := range strings.Split(ev.Data, "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteString("\n")
+	return b.Bytes()
+}
@@ -0,0 +1,78 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// slowWriter block writes until gate is closed, signalling writing on the first blocked write.
+type slowWriter struct {
+	*httptest.ResponseRecorder
+	writing chan struct{}
+	gate    chan struct{}
+}
+
+func (sw *slowWriter) Write(p []byte) (int, error) {
+	select {
+	case sw.writing <- struct{}{}:
+	default:
+	}
+	<-sw.gate
+	return sw.ResponseRecorder.Write(p)
+}
+
+func newSlowWriter() *slowWriter {
+	return &slowWriter{ResponseRecorder: httptest.NewRecorder(), writing: make(chan struct{}, 1), gate: make(chan struct{})}
+}
+
+// sendSlowly send first event, wait until it is being written slowly, then send the rest.
+func sendSlowly(sw *slowWriter, events chan<- SSEEvent, data ...string) {
+	events <- SSEEvent{Data: data[0]}
+	<-sw.writing
+	for _, d := range data[1:] {
+		events <- SSEEvent{Data: d}
+	}
+	close(events)
+	// let the queue settle before client catches up.
+	time.Sleep(50 * time.Millisecond)
+	close(sw.gate)
+}
+
+func TestResponseSSE_DropOldest(t *testing.T) {
+	sw := newSlowWriter()
+	events := make(chan SSEEvent)
+	go sendSlowly(sw, events, "1", "2", "3", "4", "5")
+	err := ResponseSSE(newResponseWriter(sw, "", ""), httptest.NewRequest(http.MethodGet, "/events", nil), events, &SSEOpts{BufferSize: 2})
+	if err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	expected := "data: 1\n\n: dropped 2 events\n\ndata: 4\n\ndata: 5\n\n"
+	if sw.Body.String() != expected {
+		t.Errorf("%s expected %q, returned %q", t.Name(), expected, sw.Body.String())
+	}
+	if sw.Header().Get("Content-Type") != "text/event-stream" {
+		t.Errorf("%s expected text/event-stream, returned %s", t.Name(), sw.Header().Get("Content-Type"))
+	}
+}
+
+func TestResponseSSE_Disconnect(t *testing.T) {
+	sw := newSlowWriter()
+	events := make(chan SSEEvent)
+	go sendSlowly(sw, events, "1", "2", "3")
+	err := ResponseSSE(newResponseWriter(sw, "", ""), httptest.NewRequest(http.MethodGet, "/events", nil), events, &SSEOpts{BufferSize: 1, Policy: SSEDisconnect})
+	if err != ErrSlowConsumer {
+		t.Errorf("%s expected %v, returned %v", t.Name(), ErrSlowConsumer, err)
+	}
+	if sw.Body.String() != "data: 1\n\n" {
+		t.Errorf("%s expected only first event written, returned %q", t.Name(), sw.Body.String())
+	}
+}
+
+func TestEncodeSSE(t *testing.T) {
+	expected := "id: 7\nevent: update\ndata: a\ndata: b\n\n"
+	if b := encodeSSE(SSEEvent{ID: "7", Event: "update", Data: "a\nb"}, 0); string(b) != expected {
+		t.Errorf("%s expected %q, returned %q", t.Name(), expected, b)
+	}
+}
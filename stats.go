@@ -0,0 +1,191 @@
+package httpserver
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// Stats snapshot of request counters since the server is created.
+type Stats struct {
+	// Total number of completed requests.
+	Total uint64
+
+	// Number of completed requests per status class.
+	Status1xx uint64
+	Status2xx uint64
+	Status3xx uint64
+	Status4xx uint64
+	Status5xx uint64
+
+	// InFlight number of requests currently being handled.
+	InFlight int64
+
+	// BytesWritten number of response body bytes actually sent.
+	BytesWritten uint64
+	// WriteErrors number of requests whose response failed to be written, e.g. client has gone away.
+	WriteErrors uint64
+
+	// ConnsAccepted number of accepted client connections.
+	ConnsAccepted uint64
+	// ConnsActive number of connections currently reading or handling a request.
+	ConnsActive int64
+	// ConnsIdle number of keep-alive connections currently waiting for the next request.
+	// High ConnsAccepted relative to Total means keep-alive is not effective.
+	ConnsIdle int64
+
+	// DroppedLogs number of log lines dropped because the log buffer was full.
+	DroppedLogs uint64
+}
+
+// stats counters updated atomically, read via Server.Stats.
+type stats struct {
+	total     uint64
+	status1xx uint64
+	status2xx uint64
+	status3xx uint64
+	status4xx uint64
+	status5xx uint64
+	inFlight  int64
+
+	bytesWritten uint64
+	writeErrors  uint64
+
+	connsAccepted uint64
+	connsActive   int64
+	connsIdle     int64
+	droppedLogs   uint64
+	// connStates last state of each open connection.
+	connStates sync.Map
+}
+
+// Stats return snapshot of request counters.
+// Requests are counted by Handler, used by Run and Start, including those not matching any route.
+func (s *Server) Stats() Stats {
+	return Stats{
+		Total:     atomic.LoadUint64(&s.stats.total),
+		Status1xx: atomic.LoadUint64(&s.stats.status1xx),
+		Status2xx: atomic.LoadUint64(&s.stats.status2xx),
+		Status3xx: atomic.LoadUint64(&s.stats.status3xx),
+		Status4xx: atomic.LoadUint64(&s.stats.status4xx),
+		Status5xx: atomic.LoadUint64(&s.stats.status5xx),
+		InFlight:  atomic.LoadInt64(&s.stats.inFlight),
+
+		BytesWritten: atomic.LoadUint64(&s.stats.bytesWritten),
+		WriteErrors:  atomic.LoadUint64(&s.stats.writeErrors),
+
+		ConnsAccepted: atomic.LoadUint64(&s.stats.connsAccepted),
+		ConnsActive:   atomic.LoadInt64(&s.stats.connsActive),
+		ConnsIdle:     atomic.LoadInt64(&s.stats.connsIdle),
+
+		DroppedLogs: atomic.LoadUint64(&s.stats.droppedLogs),
+	}
+}
+
+// trackConn update connection counters on state change of c, then call ConnState option if set.
+// Connections only count once the server is started via Start or Run.
+func (s *Server) trackConn(c net.Conn, state http.ConnState) {
+	if prev, ok := s.stats.connStates.Load(c); ok {
+		switch prev.(http.ConnState) {
+		case http.StateActive:
+			atomic.AddInt64(&s.stats.connsActive, -1)
+		case http.StateIdle:
+			atomic.AddInt64(&s.stats.connsIdle, -1)
+		}
+	}
+	switch state {
+	case http.StateNew:
+		atomic.AddUint64(&s.stats.connsAccepted, 1)
+	case http.StateActive:
+		atomic.AddInt64(&s.stats.connsActive, 1)
+	case http.StateIdle:
+		atomic.AddInt64(&s.stats.connsIdle, 1)
+	}
+	if state == http.StateClosed || state == http.StateHijacked {
+		s.stats.connStates.Delete(c)
+	} else {
+		s.stats.connStates.Store(c, state)
+	}
+	if s.connState != nil {
+		s.connState(c, state)
+	}
+}
+
+// countHandler update stats counters around next. It wraps the whole Handler, so requests responded
+// without reaching a route, e.g. not found, method not allowed or URI too long, are counted too.
+func (s *Server) countHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&s.stats.inFlight, 1)
+		sw := &statsWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		defer func() {
+			atomic.AddInt64(&s.stats.inFlight, -1)
+			atomic.AddUint64(&s.stats.total, 1)
+			atomic.AddUint64(&s.stats.bytesWritten, uint64(sw.bytesWritten))
+			if sw.writeErr != nil {
+				atomic.AddUint64(&s.stats.writeErrors, 1)
+			}
+			switch sw.statusCode / 100 {
+			case 1:
+				atomic.AddUint64(&s.stats.status1xx, 1)
+			case 2:
+				atomic.AddUint64(&s.stats.status2xx, 1)
+			case 3:
+				atomic.AddUint64(&s.stats.status3xx, 1)
+			case 4:
+				atomic.AddUint64(&s.stats.status4xx, 1)
+			case 5:
+				atomic.AddUint64(&s.stats.status5xx, 1)
+			}
+		}()
+		next.ServeHTTP(sw, r)
+	})
+}
+
+// statsWriter record status code and body bytes of the response for countHandler.
+type statsWriter struct {
+	http.ResponseWriter
+	statusCode   int
+	wroteHeader  bool
+	bytesWritten int64
+	writeErr     error
+}
+
+func (sw *statsWriter) WriteHeader(statusCode int) {
+	// informational 1xx, e.g. 103 Early Hints, is followed by the final status.
+	if !sw.wroteHeader && statusCode >= http.StatusOK {
+		sw.wroteHeader = true
+		sw.statusCode = statusCode
+	}
+	sw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (sw *statsWriter) Write(p []byte) (int, error) {
+	sw.wroteHeader = true
+	n, err := sw.ResponseWriter.Write(p)
+	sw.bytesWritten += int64(n)
+	if err != nil && sw.writeErr == nil {
+		sw.writeErr = err
+	}
+	return n, err
+}
+
+// Flush flush the underlying http.ResponseWriter if it is http.Flusher.
+func (sw *statsWriter) Flush() {
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Push forward http/2 server push into the underlying http.ResponseWriter.
+func (sw *statsWriter) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := sw.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// Unwrap return the underlying http.ResponseWriter.
+func (sw *statsWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
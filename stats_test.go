@@ -0,0 +1,121 @@
+package httpserver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	srv := New(&Opts{})
+	var inFlight int64
+	srv.GET("/status/:code", func(w http.ResponseWriter, r *http.Request) {
+		inFlight = srv.Stats().InFlight
+		code, _ := strconv.Atoi(r.URL.Query().Get("code"))
+		ResponseStatus(w, code)
+	})
+	srv.GET("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("test panic")
+	})
+
+	h := srv.Handler()
+	for _, code := range []int{200, 201, 204, 301, 404, 400, 500} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/status/"+strconv.Itoa(code), nil))
+	}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	expected := Stats{
+		Total:     8,
+		Status2xx: 3,
+		Status3xx: 1,
+		Status4xx: 2,
+		Status5xx: 2,
+
+		BytesWritten: uint64(len("httpserver got panic")),
+	}
+	if stats := srv.Stats(); stats != expected {
+		t.Errorf("%s expected %+v, returned %+v", t.Name(), expected, stats)
+	}
+	if inFlight != 1 {
+		t.Errorf("%s expected in flight %d inside handler, returned %d", t.Name(), 1, inFlight)
+	}
+}
+
+func TestStats_WriteError(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GET("/large", func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusOK, "large body")
+	})
+	srv.Handler().ServeHTTP(&brokenWriter{ResponseRecorder: httptest.NewRecorder(), limit: 5}, httptest.NewRequest(http.MethodGet, "/large", nil))
+
+	stats := srv.Stats()
+	if stats.BytesWritten != 5 {
+		t.Errorf("%s expected %d, returned %d", t.Name(), 5, stats.BytesWritten)
+	}
+	if stats.WriteErrors != 1 {
+		t.Errorf("%s expected %d, returned %d", t.Name(), 1, stats.WriteErrors)
+	}
+}
+
+func TestStats_Unrouted(t *testing.T) {
+	srv := New(&Opts{StripPrefix: "/service", MaxURLLength: 32})
+	srv.GET("/ok", okHandler)
+	h := srv.Handler()
+	for _, path := range []string{"/service/ok", "/service/missing", "/other", "/service/ok?" + strings.Repeat("q", 32)} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/service/ok", nil))
+
+	if stats := srv.Stats(); stats.Total != 5 || stats.Status2xx != 1 || stats.Status4xx != 4 {
+		t.Errorf("%s expected %d total with %d 4xx, returned %+v", t.Name(), 5, 4, stats)
+	}
+}
+
+// waitStats poll srv stats until cond holds or timeout, as connection state changes asynchronously.
+func waitStats(srv *Server, cond func(Stats) bool) Stats {
+	deadline := time.Now().Add(time.Second)
+	for {
+		stats := srv.Stats()
+		if cond(stats) || time.Now().After(deadline) {
+			return stats
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestStats_Conns(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GET("/ok", func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusOK, "ok")
+	})
+	if err := srv.Start(); err != nil {
+		t.Fatalf("%s expected null error, found %v", t.Name(), err)
+	}
+	defer srv.server.Close()
+
+	client := &http.Client{Transport: &http.Transport{}}
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(fmt.Sprintf("http://%s/ok", srv.listener.Addr()))
+		if err != nil {
+			t.Fatalf("%s expected null error, found %v", t.Name(), err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	stats := waitStats(srv, func(s Stats) bool { return s.ConnsIdle == 1 })
+	if stats.ConnsAccepted != 1 || stats.ConnsActive != 0 || stats.ConnsIdle != 1 {
+		t.Errorf("%s expected 1 accepted idle connection reused, returned %+v", t.Name(), stats)
+	}
+
+	client.CloseIdleConnections()
+	stats = waitStats(srv, func(s Stats) bool { return s.ConnsIdle == 0 })
+	if stats.ConnsAccepted != 1 || stats.ConnsActive != 0 || stats.ConnsIdle != 0 {
+		t.Errorf("%s expected no open connection, returned %+v", t.Name(), stats)
+	}
+}
@@ -0,0 +1,242 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// RequestTimeoutHeader header containing client supplied timeout, e.g. `X-Request-Timeout: 2s`.
+const RequestTimeoutHeader = "X-Request-Timeout"
+
+// Timeout middleware to cut off handler running longer than d by responding 504 Gateway Timeout.
+// Request context is cancelled on timeout, handler should watch r.Context().Done() to stop its work.
+// Anything written by handler after timeout is discarded, unless handler has flushed, e.g. streaming response,
+// then its response is already on the wire and it is cut off without 504.
+// Panic of handler is propagated with its own stack, panic after timeout is logged since nothing is left to respond it.
+func Timeout(d time.Duration) Middleware {
+	return func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			timeout(w, r, next, d)
+		}
+	}
+}
+
+// RequestTimeout middleware to honor client supplied timeout from RequestTimeoutHeader, bounded by max.
+// Header value is a duration string, e.g. "500ms" or "2s".
+// Header is ignored if absent or malformed.
+func RequestTimeout(max time.Duration) Middleware {
+	return func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			d, ok := headerTimeout(r, RequestTimeoutHeader, max)
+			if !ok {
+				next(w, r)
+				return
+			}
+			timeout(w, r, next, d)
+		}
+	}
+}
+
+// DeadlineFromHeader middleware to apply client supplied timeout from header, bounded by max, as request context deadline.
+// Header value is a duration string, e.g. "500ms" or "2s", ignored if absent or malformed.
+// Unlike RequestTimeout, nothing is responded on deadline, handler should watch r.Context().Done() and respond itself.
+func DeadlineFromHeader(header string, max time.Duration) Middleware {
+	return func(next http.HandlerFunc, params ...interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			d, ok := headerTimeout(r, header, max)
+			if !ok {
+				next(w, r)
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next(w, r.WithContext(ctx))
+		}
+	}
+}
+
+// headerTimeout return client supplied timeout in header of r bounded by max, false if absent, malformed or not positive.
+func headerTimeout(r *http.Request, header string, max time.Duration) (time.Duration, bool) {
+	d, err := time.ParseDuration(r.Header.Get(header))
+	if err != nil || d <= 0 {
+		return 0, false
+	}
+	if d > max {
+		d = max
+	}
+	return d, true
+}
+
+func timeout(w http.ResponseWriter, r *http.Request, next http.HandlerFunc, d time.Duration) {
+	ctx, cancel := context.WithTimeout(r.Context(), d)
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	tw := &timeoutWriter{w: w, header: make(http.Header), statusCode: http.StatusOK}
+	done := make(chan struct{})
+	panicChan := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			if rcv := recover(); rcv != nil {
+				if rcv != http.ErrAbortHandler {
+					rcv = &stackedPanic{value: rcv, stack: debug.Stack()}
+				}
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				if tw.timedOut {
+					if rcv != http.ErrAbortHandler {
+						logLatePanic(r, rcv.(*stackedPanic))
+					}
+					return
+				}
+				panicChan <- rcv
+			}
+		}()
+		next(tw, r)
+		close(done)
+	}()
+
+	select {
+	case rcv := <-panicChan:
+		// re-panic in this goroutine so recoverPanic can handle it, stack of handler is carried by stackedPanic.
+		panic(rcv)
+	case <-done:
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+		tw.writeHeader()
+		w.Write(tw.body.Bytes())
+	case <-ctx.Done():
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+		select {
+		case rcv := <-panicChan: // handler panicked right at the deadline, it is not late.
+			panic(rcv)
+		default:
+		}
+		tw.timedOut = true
+		if ctx.Err() == context.DeadlineExceeded && !tw.flushed {
+			ResponseString(w, http.StatusGatewayTimeout, http.StatusText(http.StatusGatewayTimeout))
+		}
+	}
+}
+
+// stackedPanic panic recovered in other goroutine, re-panicked along with the stack where it happened.
+type stackedPanic struct {
+	value interface{}
+	stack []byte
+}
+
+// logLatePanic log panic of handler happened after it timed out, through the server logger if any.
+func logLatePanic(r *http.Request, p *stackedPanic) {
+	logger := log.New(os.Stderr, "", 0)
+	if s, ok := serverFromContext(r.Context()); ok {
+		logger = s.logger
+	}
+	logger.Printf("%s | httpserver | %s | %s | %s | %s | panic after timeout: %v\n%s", time.Now().Format(time.RFC3339), "PANIC", r.Method, r.URL.Path, r.Header.Get("Request-Id"), p.value, p.stack)
+}
+
+// timeoutWriter buffer the response so it can be discarded if handler times out.
+type timeoutWriter struct {
+	w      http.ResponseWriter
+	header http.Header
+
+	mu          sync.Mutex
+	body        bytes.Buffer
+	statusCode  int
+	wroteHeader bool
+	timedOut    bool
+	// flushed whether header and body so far are written into w by Flush, later writes go directly into w.
+	flushed bool
+}
+
+// writeHeader copy buffered header and status into w once. tw.mu must be held.
+func (tw *timeoutWriter) writeHeader() {
+	if tw.flushed {
+		return
+	}
+	tw.flushed = true
+	dst := tw.w.Header()
+	for k, v := range tw.header {
+		dst[k] = v
+	}
+	tw.w.WriteHeader(tw.statusCode)
+}
+
+// Flush write buffered response into w and flush it, e.g. for streaming response.
+// Once flushed, response can not be replaced with 504 on timeout anymore.
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	tw.writeHeader()
+	tw.w.Write(tw.body.Bytes())
+	tw.body.Reset()
+	if f, ok := tw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(statusCode int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	tw.statusCode = statusCode
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.wroteHeader = true
+	if tw.flushed {
+		return tw.w.Write(p)
+	}
+	return tw.body.Write(p)
+}
+
+// Unwrap return the wrapped http.ResponseWriter.
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.w
+}
+
+// GETWithTimeout register GET handler cut off after d like wrapped by Timeout(d) placed first of the route middlewares.
+func (s *Server) GETWithTimeout(path string, d time.Duration, handler http.HandlerFunc, middlewares ...Middleware) *Route {
+	return s.MethodWithTimeout(http.MethodGet, path, d, handler, middlewares...)
+}
+
+// MethodWithTimeout register handler for any method cut off after d like wrapped by Timeout(d) placed first of the route middlewares.
+func (s *Server) MethodWithTimeout(method string, path string, d time.Duration, handler http.HandlerFunc, middlewares ...Middleware) *Route {
+	return s.register(method, path, handler, withTimeout(d, middlewares)...)
+}
+
+// GETWithTimeout register GET handler in a group path cut off after d, see Server.GETWithTimeout.
+func (g *Group) GETWithTimeout(path string, d time.Duration, handler http.HandlerFunc, middlewares ...Middleware) *Route {
+	return g.MethodWithTimeout(http.MethodGet, path, d, handler, middlewares...)
+}
+
+// MethodWithTimeout register handler for any method in a group path cut off after d, see Server.MethodWithTimeout.
+func (g *Group) MethodWithTimeout(method string, path string, d time.Duration, handler http.HandlerFunc, middlewares ...Middleware) *Route {
+	return g.register(method, path, handler, withTimeout(d, middlewares)...)
+}
+
+// withTimeout return Timeout(d) followed by middlewares.
+func withTimeout(d time.Duration, middlewares []Middleware) []Middleware {
+	return append([]Middleware{Timeout(d)}, middlewares...)
+}
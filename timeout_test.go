@@ -0,0 +1,213 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func slowHandler(w http.ResponseWriter, r *http.Request) {
+	select {
+	case <-r.Context().Done():
+		return
+	case <-time.After(time.Second):
+		ResponseString(w, http.StatusOK, "done")
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GET("/slow", slowHandler, RequestTimeout(time.Second))
+
+	r := httptest.NewRequest(http.MethodGet, "/slow", nil)
+	r.Header.Set(RequestTimeoutHeader, "50ms")
+	w := httptest.NewRecorder()
+	start := time.Now()
+	srv.handlers.ServeHTTP(w, r)
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusGatewayTimeout, w.Code)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("%s expected cut off before handler finished, took %v", t.Name(), elapsed)
+	}
+}
+
+func TestRequestTimeout_ClampedToMax(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GET("/slow", slowHandler, RequestTimeout(50*time.Millisecond))
+
+	r := httptest.NewRequest(http.MethodGet, "/slow", nil)
+	r.Header.Set(RequestTimeoutHeader, "10s")
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, r)
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusGatewayTimeout, w.Code)
+	}
+}
+
+func TestRequestTimeout_Ignored(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GET("/fast", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); ok {
+			t.Errorf("%s expected no deadline", t.Name())
+		}
+		ResponseString(w, http.StatusOK, "ok")
+	}, RequestTimeout(time.Second))
+
+	for _, v := range []string{"", "abc", "-1s"} {
+		r := httptest.NewRequest(http.MethodGet, "/fast", nil)
+		r.Header.Set(RequestTimeoutHeader, v)
+		w := httptest.NewRecorder()
+		srv.handlers.ServeHTTP(w, r)
+		if w.Code != http.StatusOK || w.Body.String() != "ok" {
+			t.Errorf("%s expected %d ok, returned %d %s", t.Name(), http.StatusOK, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestTimeout_Completed(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GET("/fast", func(w http.ResponseWriter, r *http.Request) {
+		ResponseString(w, http.StatusCreated, "created")
+	}, Timeout(time.Second))
+
+	r := httptest.NewRequest(http.MethodGet, "/fast", nil)
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, r)
+	if w.Code != http.StatusCreated || w.Body.String() != "created" {
+		t.Errorf("%s expected %d created, returned %d %s", t.Name(), http.StatusCreated, w.Code, w.Body.String())
+	}
+	if w.Header().Get("Request-Id") == "" {
+		t.Errorf("%s expected Header Request-Id not empty, found empty", t.Name())
+	}
+}
+
+func TestDeadlineFromHeader(t *testing.T) {
+	var ctxErr error
+	srv := New(&Opts{})
+	srv.GET("/slow", func(w http.ResponseWriter, r *http.Request) {
+		slowHandler(w, r)
+		ctxErr = r.Context().Err()
+	}, DeadlineFromHeader(RequestTimeoutHeader, time.Second))
+
+	r := httptest.NewRequest(http.MethodGet, "/slow", nil)
+	r.Header.Set(RequestTimeoutHeader, "50ms")
+	start := time.Now()
+	srv.handlers.ServeHTTP(httptest.NewRecorder(), r)
+	if ctxErr != context.DeadlineExceeded {
+		t.Errorf("%s expected %v, returned %v", t.Name(), context.DeadlineExceeded, ctxErr)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("%s expected handler cancelled early, took %v", t.Name(), elapsed)
+	}
+
+	hasDeadline := true
+	srv.GET("/deadline", func(w http.ResponseWriter, r *http.Request) {
+		_, hasDeadline = r.Context().Deadline()
+	}, DeadlineFromHeader(RequestTimeoutHeader, time.Second))
+	r = httptest.NewRequest(http.MethodGet, "/deadline", nil)
+	r.Header.Set(RequestTimeoutHeader, "invalid")
+	srv.handlers.ServeHTTP(httptest.NewRecorder(), r)
+	if hasDeadline {
+		t.Errorf("%s expected no deadline for invalid header", t.Name())
+	}
+}
+
+func TestGETWithTimeout(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GETWithTimeout("/slow", 50*time.Millisecond, slowHandler)
+	srv.Group("/v1").GETWithTimeout("/slow", 50*time.Millisecond, slowHandler)
+
+	for _, path := range []string{"/slow", "/v1/slow"} {
+		w := httptest.NewRecorder()
+		start := time.Now()
+		srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusGatewayTimeout {
+			t.Errorf("%s %s expected %d, returned %d", t.Name(), path, http.StatusGatewayTimeout, w.Code)
+		}
+		if elapsed := time.Since(start); elapsed >= time.Second {
+			t.Errorf("%s %s expected handler cut off early, took %v", t.Name(), path, elapsed)
+		}
+	}
+}
+
+func TestTimeout_Panic(t *testing.T) {
+	var buf bytes.Buffer
+	srv := New(&Opts{})
+	srv.logger = log.New(&buf, "", 0)
+	srv.GET("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panicInHandler()
+	}, Timeout(time.Second))
+
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusInternalServerError, w.Code)
+	}
+	if !strings.Contains(buf.String(), "panicInHandler") {
+		t.Errorf("%s expected stack of handler logged, returned %s", t.Name(), buf.String())
+	}
+}
+
+func panicInHandler() {
+	panic("handler panic")
+}
+
+func TestTimeout_LatePanic(t *testing.T) {
+	logged := make(chan string, 1)
+	srv := New(&Opts{})
+	srv.logger = log.New(writerFunc(func(p []byte) (int, error) {
+		logged <- string(p)
+		return len(p), nil
+	}), "", 0)
+	srv.GET("/late", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		time.Sleep(20 * time.Millisecond) // let the timeout be responded first.
+		panicInHandler()
+	}, Timeout(10*time.Millisecond))
+
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/late", nil))
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("%s expected %d, returned %d", t.Name(), http.StatusGatewayTimeout, w.Code)
+	}
+	select {
+	case s := <-logged:
+		if !strings.Contains(s, "panic after timeout: handler panic") || !strings.Contains(s, "panicInHandler") {
+			t.Errorf("%s expected late panic logged with stack, returned %s", t.Name(), s)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("%s expected late panic logged", t.Name())
+	}
+}
+
+// writerFunc adapt function into io.Writer.
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+func TestTimeout_Flush(t *testing.T) {
+	srv := New(&Opts{})
+	srv.GET("/stream", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("first"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("late"))
+	}, Timeout(20*time.Millisecond))
+
+	w := httptest.NewRecorder()
+	srv.handlers.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stream", nil))
+	if w.Code != http.StatusAccepted || w.Body.String() != "first" || !w.Flushed || w.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("%s expected flushed %d first, returned %d %s %v", t.Name(), http.StatusAccepted, w.Code, w.Body.String(), w.Header())
+	}
+}